@@ -0,0 +1,51 @@
+package main
+
+import "math"
+
+const (
+	minZoom = 1
+	maxZoom = 64
+)
+
+// camera maps world cell coordinates to pixels inside the view. The cell at
+// world x, y is drawn at view pixel ((x-camera.x)*zoom, (y-camera.y)*zoom).
+type camera struct {
+	x, y float64
+	// zoom is the size of a cell in pixels.
+	zoom float64
+}
+
+// toWorld returns the cell under the view pixel px, py.
+func (c *camera) toWorld(px, py int) (x, y int) {
+	wx := c.x + float64(px)/c.zoom
+	wy := c.y + float64(py)/c.zoom
+	return int(math.Floor(wx)), int(math.Floor(wy))
+}
+
+// toView returns the view pixel at the top left corner of the cell at x, y.
+func (c *camera) toView(x, y int) (px, py float64) {
+	return (float64(x) - c.x) * c.zoom, (float64(y) - c.y) * c.zoom
+}
+
+// pan moves the camera so that the view follows a drag of dx, dy pixels.
+func (c *camera) pan(dx, dy int) {
+	c.x -= float64(dx) / c.zoom
+	c.y -= float64(dy) / c.zoom
+}
+
+// zoomAt multiplies the zoom by factor, keeping the point under the view
+// pixel px, py where it is.
+func (c *camera) zoomAt(px, py int, factor float64) {
+	wx := c.x + float64(px)/c.zoom
+	wy := c.y + float64(py)/c.zoom
+	c.zoom = math.Max(minZoom, math.Min(maxZoom, c.zoom*factor))
+	c.x = wx - float64(px)/c.zoom
+	c.y = wy - float64(py)/c.zoom
+}
+
+// centreOn moves the camera so that the world point x, y is in the middle of
+// a view of the given size.
+func (c *camera) centreOn(x, y float64, viewWidth, viewHeight int) {
+	c.x = x - float64(viewWidth)/2/c.zoom
+	c.y = y - float64(viewHeight)/2/c.zoom
+}
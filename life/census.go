@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"epractice/life/sim"
+)
+
+// soupSize is the width and height of the random soups.
+const soupSize = 16
+
+// censusEntry counts one kind of object over all soups.
+type censusEntry struct {
+	label  string
+	kind   objectKind
+	period int
+	count  int
+	// soups is how many soups left at least one.
+	soups int
+}
+
+// census is what a soup search found.
+type census struct {
+	soups       int
+	unsettled   int
+	generations int
+	entries     map[string]*censusEntry
+}
+
+// runCensus searches random soups and prints a census of the objects they
+// leave behind, in the manner of apgsearch. It backs the census command:
+//
+//	life census -soups 1000 -seed 42
+func runCensus(args []string) error {
+	fs := flag.NewFlagSet("census", flag.ExitOnError)
+	soupsFlag := fs.Int("soups", 100, "number of soups to run")
+	seedFlag := fs.Int64("seed", 1, "seed of the random soups, the same seed gives the same soups")
+	ruleFlag := fs.String("rule", sim.Conway.String(), "Life-like rule in B/S notation or by name, objects are only named under Conway's rule")
+	sizeFlag := fs.Int("torus", 96, "width and height of the torus the soups run on")
+	maxGensFlag := fs.Int("max-gens", 20000, "generations a soup may take to settle before it is given up on")
+	densityFlag := fs.Float64("density", 0.5, "share of the cells of a soup that start alive")
+	outFlag := fs.String("o", "-", "output `file`, - for stdout")
+	workersFlag := fs.Int("workers", sim.Workers(), "goroutines stepping bands of rows of the torus")
+	fs.Parse(args)
+
+	sim.SetWorkers(*workersFlag)
+
+	rule, err := sim.ParseRule(*ruleFlag)
+	if err != nil {
+		return err
+	}
+	switch {
+	case rule.States() != 2:
+		return errors.New("census: only Life-like rules have objects to count")
+	case *soupsFlag <= 0:
+		return errors.New("census: -soups must be positive")
+	case *sizeFlag < 2*soupSize:
+		return fmt.Errorf("census: -torus must be at least %d", 2*soupSize)
+	case *maxGensFlag <= 0:
+		return errors.New("census: -max-gens must be positive")
+	case !(*densityFlag >= 0 && *densityFlag <= 1):
+		return errors.New("census: -density must be between 0 and 1")
+	}
+
+	c := census{entries: make(map[string]*censusEntry)}
+	rng := rand.New(rand.NewSource(*seedFlag))
+	for i := 0; i < *soupsFlag; i++ {
+		c.run(rng, rule, *sizeFlag, *maxGensFlag, *densityFlag)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	if *outFlag != "-" {
+		f, err := os.Create(*outFlag)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = bufio.NewWriter(f)
+	}
+	fmt.Fprintf(w, "Census of %d soups of %dx%d, seed %d, rule %s, on a %dx%d torus\n",
+		c.soups, soupSize, soupSize, *seedFlag, rule.Label(), *sizeFlag, *sizeFlag)
+	if err := c.write(w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// run makes a soup, runs it until it settles into a cycle and counts the
+// objects that are left.
+func (c *census) run(rng *rand.Rand, rule sim.Rule, size, maxGens int, density float64) {
+	c.soups++
+
+	u := sim.NewFinite(size, size, sim.Torus)
+	x0, y0 := (size-soupSize)/2, (size-soupSize)/2
+	for y := 0; y < soupSize; y++ {
+		for x := 0; x < soupSize; x++ {
+			if rng.Float64() < density {
+				u.SetCell(x0+x, y0+y, 1)
+			}
+		}
+	}
+
+	var cycle cycleDetector
+	for gen := 0; !cycle.check(u.Hash(), gen, u.Population()); gen++ {
+		if gen == maxGens {
+			c.unsettled++
+			return
+		}
+		u.Step(rule)
+	}
+	c.generations += cycle.start
+
+	seen := make(map[string]bool)
+	for _, o := range findObjects(u, sim.Torus, size, size, rule) {
+		e := c.entries[o.key]
+		if e == nil {
+			e = &censusEntry{label: o.label(), kind: o.kind, period: o.period}
+			c.entries[o.key] = e
+		}
+		e.count++
+		if !seen[o.key] {
+			seen[o.key] = true
+			e.soups++
+		}
+	}
+}
+
+// write prints the census as a table, the most common objects first.
+func (c *census) write(w io.Writer) error {
+	settled := c.soups - c.unsettled
+	if settled > 0 {
+		fmt.Fprintf(w, "%d settled, after %d generations on average\n", settled, c.generations/settled)
+	}
+	if c.unsettled > 0 {
+		fmt.Fprintf(w, "%d did not settle and are not counted\n", c.unsettled)
+	}
+	fmt.Fprintln(w)
+
+	entries := make([]*censusEntry, 0, len(c.entries))
+	for _, e := range c.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].count != entries[j].count {
+			return entries[i].count > entries[j].count
+		}
+		return entries[i].label < entries[j].label
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Count\tSoups\tObject\tKind")
+	for _, e := range entries {
+		kind := e.kind.String()
+		if e.kind == oscillator || e.kind == spaceship {
+			kind = fmt.Sprintf("%s, period %d", kind, e.period)
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\n", e.count, e.soups, e.label, kind)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCensus runs a few soups twice with the same seed and checks that the
+// census comes out the same and holds the commonest ash.
+func TestCensus(t *testing.T) {
+	dir := t.TempDir()
+	var outputs []string
+	for _, name := range []string{"a.txt", "b.txt"} {
+		path := filepath.Join(dir, name)
+		if err := runCensus([]string{"-soups", "10", "-seed", "7", "-o", path}); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, string(data))
+	}
+
+	if outputs[0] != outputs[1] {
+		t.Errorf("the same seed gave different censuses:\n%s\n%s", outputs[0], outputs[1])
+	}
+	for _, want := range []string{"Census of 10 soups", "Block", "Blinker"} {
+		if !strings.Contains(outputs[0], want) {
+			t.Errorf("census does not mention %q:\n%s", want, outputs[0])
+		}
+	}
+}
+
+func TestCensusRejectsBadFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"-density", "1.5"},
+		{"-density", "-0.1"},
+		{"-density", "NaN"},
+		{"-max-gens", "0"},
+		{"-soups", "0"},
+		{"-torus", "20"},
+		{"-rule", "B2/S/C3"},
+	} {
+		if err := runCensus(args); err == nil {
+			t.Errorf("%v: no error", args)
+		}
+	}
+}
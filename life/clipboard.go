@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommand is a program that copies standard input to the system
+// clipboard, and one that prints the clipboard.
+type clipboardCommand struct {
+	copy  []string
+	paste []string
+}
+
+// clipboardCommands lists the clipboard programs to try on each system, in
+// order of preference.
+var clipboardCommands = map[string][]clipboardCommand{
+	"darwin": {
+		{[]string{"pbcopy"}, []string{"pbpaste"}},
+	},
+	"windows": {
+		{[]string{"clip.exe"}, []string{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard"}},
+	},
+	"linux": {
+		{[]string{"wl-copy"}, []string{"wl-paste", "--no-newline"}},
+		{[]string{"xclip", "-selection", "clipboard"}, []string{"xclip", "-selection", "clipboard", "-o"}},
+		{[]string{"xsel", "--clipboard", "--input"}, []string{"xsel", "--clipboard", "--output"}},
+	},
+}
+
+var errNoClipboard = errors.New("no clipboard program found")
+
+// writeClipboard puts text on the system clipboard. Programs that are
+// installed but fail, such as wl-copy outside Wayland, are skipped.
+func writeClipboard(text string) error {
+	err := errNoClipboard
+	for _, c := range clipboardCommands[runtime.GOOS] {
+		if _, lookErr := exec.LookPath(c.copy[0]); lookErr != nil {
+			continue
+		}
+		cmd := exec.Command(c.copy[0], c.copy[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err = cmd.Run(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// readClipboard returns the text on the system clipboard.
+func readClipboard() (string, error) {
+	err := errNoClipboard
+	for _, c := range clipboardCommands[runtime.GOOS] {
+		if _, lookErr := exec.LookPath(c.paste[0]); lookErr != nil {
+			continue
+		}
+		var out []byte
+		if out, err = exec.Command(c.paste[0], c.paste[1:]...).Output(); err == nil {
+			return string(out), nil
+		}
+	}
+	return "", err
+}
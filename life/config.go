@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// config is the size of the board and the window, read from a JSON config
+// file. Flags override it.
+type config struct {
+	Rows int `json:"rows"`
+	Cols int `json:"cols"`
+	// CellSize is the size of a cell in pixels at the start.
+	CellSize float64 `json:"cellSize"`
+	// Width and Height are the size of the window at the start.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+var defaultConfig = config{
+	Rows:     30,
+	Cols:     30,
+	CellSize: 20,
+	Width:    960,
+	Height:   720,
+}
+
+// configPath returns where the config file is looked for when no -config
+// flag is given, in the user's configuration directory.
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "life", "config.json"), nil
+}
+
+// loadConfig reads the config file at path over the defaults. A missing file
+// is only an error when must is set.
+func loadConfig(path string, must bool) (config, error) {
+	c := defaultConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !must && errors.Is(err, os.ErrNotExist) {
+			return c, nil
+		}
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := c.check(); err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+func (c config) check() error {
+	switch {
+	case c.Rows <= 0 || c.Cols <= 0:
+		return fmt.Errorf("board size %dx%d", c.Cols, c.Rows)
+	case c.CellSize < minZoom || c.CellSize > maxZoom:
+		return fmt.Errorf("cell size %g is not between %d and %d", c.CellSize, minZoom, maxZoom)
+	case c.Width < minScreenWidth || c.Height < minScreenHeight:
+		return fmt.Errorf("window size %dx%d is smaller than %dx%d", c.Width, c.Height, minScreenWidth, minScreenHeight)
+	}
+	return nil
+}
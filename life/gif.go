@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"epractice/life/sim"
+)
+
+// gifPalette is the colours of a recording: dead and live cells, and the
+// dying states of Generations rules fading from dyingFrom to dyingTo.
+type gifPalette struct {
+	name      string
+	dead      color.RGBA
+	live      color.RGBA
+	dyingFrom color.RGBA
+	dyingTo   color.RGBA
+}
+
+// gifPalettes are the palettes that can be picked by name. The first one
+// matches the colours on screen.
+var gifPalettes = []gifPalette{
+	{"screen", deadColor, liveColor, color.RGBA{255, 220, 60, 255}, color.RGBA{80, 0, 0, 255}},
+	{"paper", color.RGBA{255, 255, 255, 255}, color.RGBA{0, 0, 0, 255}, color.RGBA{90, 90, 90, 255}, color.RGBA{220, 220, 220, 255}},
+	{"phosphor", color.RGBA{0, 20, 0, 255}, color.RGBA{80, 255, 120, 255}, color.RGBA{30, 160, 60, 255}, color.RGBA{0, 50, 10, 255}},
+	{"amber", color.RGBA{20, 10, 0, 255}, color.RGBA{255, 176, 0, 255}, color.RGBA{170, 100, 0, 255}, color.RGBA{50, 25, 0, 255}},
+}
+
+// parseGIFPalette looks a palette up by name, or makes one from two colours
+// for dead and live cells written as hex, as in "#202020,#ffcc00".
+func parseGIFPalette(s string) (gifPalette, error) {
+	for _, p := range gifPalettes {
+		if strings.EqualFold(p.name, s) {
+			return p, nil
+		}
+	}
+
+	dead, live, ok := strings.Cut(s, ",")
+	if !ok {
+		return gifPalette{}, fmt.Errorf("unknown palette %q", s)
+	}
+	p := gifPalette{name: s}
+	var err error
+	if p.dead, err = parseHexColor(dead); err != nil {
+		return gifPalette{}, err
+	}
+	if p.live, err = parseHexColor(live); err != nil {
+		return gifPalette{}, err
+	}
+	p.dyingFrom, p.dyingTo = lerpColor(p.dead, p.live, 0.6), lerpColor(p.dead, p.live, 0.2)
+	return p, nil
+}
+
+func parseHexColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || len(s) != 6 {
+		return color.RGBA{}, fmt.Errorf("colour %q: want six hex digits", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, nil
+}
+
+// colors returns the palette for cells with the given number of states, one
+// entry per state, with the dying states spread as they are on screen.
+func (p gifPalette) colors(states int) color.Palette {
+	pal := color.Palette{p.dead, p.live}
+	for s := 2; s < states; s++ {
+		t := float64(s-2) / float64(max(states-2, 1))
+		pal = append(pal, lerpColor(p.dyingFrom, p.dyingTo, t))
+	}
+	return pal
+}
+
+const (
+	// maxGIFFrames is how many generations a recording holds at most.
+	maxGIFFrames = 5000
+	// maxGIFPixels is the most pixels a frame of a recording may have, to
+	// keep runaway patterns from using up the memory when it is written.
+	// maxGIFTotalPixels is the most of all frames together, which are all
+	// held at once while the file is written.
+	maxGIFPixels      = 1 << 22
+	maxGIFTotalPixels = 1 << 27
+)
+
+// gifFrame is the cells of one generation, one byte per cell holding its
+// state, for the cells from x, y on.
+type gifFrame struct {
+	x, y          int
+	width, height int
+	cells         []uint8
+}
+
+// gifRecorder captures generations to write them out as an animated GIF.
+// Frames are kept at one byte per cell and only scaled up and given colours
+// when written, when the area that every frame must cover is known.
+type gifRecorder struct {
+	// scale is the size of a cell in pixels and delay the time each frame
+	// is shown.
+	scale   int
+	delay   time.Duration
+	palette gifPalette
+
+	frames []gifFrame
+	// area is the cells every frame covers, the union of the frames.
+	area image.Rectangle
+	// states is the most states of the rules the frames were run under.
+	states int
+}
+
+// capture adds the current generation of g as a frame: the whole board for
+// finite topologies and the cells that are not dead on unbounded ones. It
+// returns an error, and keeps nothing, once the recording is full or its
+// frames would grow too large to write.
+func (r *gifRecorder) capture(g *Grid) error {
+	if len(r.frames) == maxGIFFrames {
+		return fmt.Errorf("a recording holds at most %d frames", maxGIFFrames)
+	}
+
+	var f gifFrame
+	if g.topology != sim.Unbounded {
+		f = gifFrame{width: g.cols, height: g.rows}
+	} else if minX, minY, maxX, maxY, ok := g.cells.Bounds(); ok {
+		f = gifFrame{x: minX, y: minY, width: maxX + 1 - minX, height: maxY + 1 - minY}
+	}
+
+	area := r.area.Union(image.Rect(f.x, f.y, f.x+f.width, f.y+f.height))
+	width, height := max(area.Dx(), 1)*r.scale, max(area.Dy(), 1)*r.scale
+	if width*height > maxGIFPixels {
+		return fmt.Errorf("frames of %dx%d pixels are too large, record at a smaller scale", width, height)
+	}
+	if (len(r.frames)+1)*width*height > maxGIFTotalPixels {
+		return fmt.Errorf("%d frames of %dx%d pixels are too large to write", len(r.frames)+1, width, height)
+	}
+	r.area = area
+	r.states = max(r.states, g.rule.States())
+
+	f.cells = make([]uint8, f.width*f.height)
+	g.cells.ForEachIn(f.x, f.y, f.x+f.width, f.y+f.height, func(x, y int, state uint8) {
+		f.cells[(y-f.y)*f.width+x-f.x] = state
+	})
+	r.frames = append(r.frames, f)
+	return nil
+}
+
+// encode writes the frames as a GIF that loops forever. Every frame covers
+// the area of all of them.
+func (r *gifRecorder) encode(w io.Writer) error {
+	if len(r.frames) == 0 {
+		return errors.New("no frames recorded")
+	}
+
+	// capture keeps the frames within maxGIFPixels.
+	area := r.area
+	if area.Empty() {
+		area = image.Rect(0, 0, 1, 1)
+	}
+	width, height := area.Dx()*r.scale, area.Dy()*r.scale
+
+	pal := r.palette.colors(r.states)
+	// GIF delays are in hundredths of a second, and most viewers slow down
+	// anything under two.
+	delay := max(int(r.delay/(10*time.Millisecond)), 2)
+	anim := &gif.GIF{
+		Config: image.Config{ColorModel: pal, Width: width, Height: height},
+	}
+	for _, f := range r.frames {
+		img := image.NewPaletted(image.Rect(0, 0, width, height), pal)
+		for y := 0; y < f.height; y++ {
+			for x := 0; x < f.width; x++ {
+				s := f.cells[y*f.width+x]
+				if s == 0 {
+					continue
+				}
+				px, py := (f.x+x-area.Min.X)*r.scale, (f.y+y-area.Min.Y)*r.scale
+				for dy := 0; dy < r.scale; dy++ {
+					row := img.Pix[(py+dy)*img.Stride+px:]
+					for dx := 0; dx < r.scale; dx++ {
+						row[dx] = s
+					}
+				}
+			}
+		}
+		anim.Image = append(anim.Image, img)
+		anim.Delay = append(anim.Delay, delay)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// save writes the recording to the file at path.
+func (r *gifRecorder) save(path string) error {
+	return writeFileSafely(path, r.encode)
+}
+
+// toggleRecording starts recording every generation, or stops and writes
+// the recording to recordingPath.
+func (g *Grid) toggleRecording() {
+	if g.recording == nil {
+		if g.saving != nil {
+			g.notice = "Still saving the last recording"
+			return
+		}
+		r := &gifRecorder{scale: recordScale, delay: recordDelay, palette: recordPalette}
+		if err := r.capture(g); err != nil {
+			g.notice = "Cannot record: " + err.Error()
+			return
+		}
+		g.recording = r
+		g.notice = "Recording, G to stop and save to " + recordingPath
+		return
+	}
+	g.stopRecording()
+}
+
+// stopRecording stops recording and saves the recording on a goroutine of
+// its own, as encoding a long one takes a while. saving brings the notice to
+// show once it is done.
+func (g *Grid) stopRecording() {
+	r := g.recording
+	g.recording = nil
+	g.notice = fmt.Sprintf("Saving %d frames to %s", len(r.frames), recordingPath)
+
+	saving := make(chan string, 1)
+	g.saving = saving
+	path := recordingPath
+	go func() {
+		if err := r.save(path); err != nil {
+			saving <- "Recording failed: " + err.Error()
+			return
+		}
+		saving <- fmt.Sprintf("Saved %d frames to %s", len(r.frames), path)
+	}()
+}
+
+// checkSaved shows how saving a recording went once it is done.
+func (g *Grid) checkSaved() {
+	if g.saving == nil {
+		return
+	}
+	select {
+	case g.notice = <-g.saving:
+		g.saving = nil
+	default:
+	}
+}
+
+// writeGIF runs the grid for n generations, recording every generation
+// from the current one on as a GIF.
+func (g *Grid) writeGIF(w io.Writer, n int, r *gifRecorder) error {
+	g.jump = 0
+	for i := 0; ; i++ {
+		if err := r.capture(g); err != nil {
+			return err
+		}
+		if i == n {
+			break
+		}
+		g.step()
+	}
+	return r.encode(w)
+}
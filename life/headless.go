@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"epractice/life/sim"
+)
+
+// runHeadless runs a pattern for a number of generations without opening a
+// window and writes out the result. It backs the run command:
+//
+//	life run -pattern glider.rle -gens 100 -o out.rle
+//	life run -pattern acorn.rle -gens 5000 -format csv > population.csv
+//	life run -pattern gun.rle -gens 300 -o gun.gif -delay 50ms
+func runHeadless(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	patternFlag := fs.String("pattern", "", "pattern `file` to run (RLE, plaintext or Life 1.06)")
+	ruleFlag := fs.String("rule", sim.Conway.String(), "rule in B/S notation or by name, overrides the rule of the pattern when given")
+	topologyFlag := fs.String("topology", sim.Unbounded.String(), "what lies past the grid edges: unbounded, bounded, torus, klein or cross")
+	rowsFlag := fs.Int("rows", 30, "board height for finite topologies")
+	colsFlag := fs.Int("cols", 30, "board width for finite topologies")
+	gensFlag := fs.Int("gens", 100, "number of generations to run")
+	hashLifeFlag := fs.Bool("hashlife", false, "run with HashLife, unbounded topology only")
+	formatFlag := fs.String("format", "", "output `format`: rle, cells, lif, png, gif or csv, taken from the -o extension when not given")
+	outFlag := fs.String("o", "-", "output `file`, - for stdout")
+	scaleFlag := fs.Int("scale", 4, "size of a cell in pixels in PNG and GIF output")
+	delayFlag := fs.Duration("delay", 100*time.Millisecond, "time each generation is shown in GIF output")
+	paletteFlag := fs.String("palette", gifPalettes[0].name, "colours of GIF output: screen, paper, phosphor, amber or dead and live colours as #rrggbb,#rrggbb")
+	workersFlag := fs.Int("workers", sim.Workers(), "goroutines stepping bands of rows of finite boards")
+	fs.Parse(args)
+
+	sim.SetWorkers(*workersFlag)
+
+	if *patternFlag == "" {
+		return errors.New("run: -pattern is required")
+	}
+	if *gensFlag < 0 {
+		return errors.New("run: -gens must not be negative")
+	}
+	if *rowsFlag <= 0 || *colsFlag <= 0 {
+		return errors.New("run: -rows and -cols must be positive")
+	}
+	if *scaleFlag <= 0 {
+		return errors.New("run: -scale must be positive")
+	}
+
+	palette, err := parseGIFPalette(*paletteFlag)
+	if err != nil {
+		return err
+	}
+
+	format := *formatFlag
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*outFlag)), ".")
+	}
+	write, err := headlessWriter(format)
+	if err != nil {
+		return err
+	}
+
+	g := &Grid{
+		rows:   *rowsFlag,
+		cols:   *colsFlag,
+		camera: camera{zoom: 1},
+		rule:   sim.Conway,
+	}
+	topology, err := sim.ParseTopology(*topologyFlag)
+	if err != nil {
+		return err
+	}
+	g.setTopology(topology)
+
+	p, err := loadPattern(*patternFlag)
+	if err != nil {
+		return err
+	}
+	if err := g.place(p); err != nil {
+		return err
+	}
+
+	ruleSet := false
+	fs.Visit(func(f *flag.Flag) {
+		ruleSet = ruleSet || f.Name == "rule"
+	})
+	if ruleSet {
+		rule, err := sim.ParseRule(*ruleFlag)
+		if err != nil {
+			return err
+		}
+		g.setRule(rule)
+	}
+
+	if *hashLifeFlag {
+		g.toggleHashLife()
+		if !g.useHashLife {
+			return errors.New("run: " + g.notice)
+		}
+	}
+
+	run := func(w io.Writer) error {
+		switch format {
+		case "csv":
+			return g.writePopulationCSV(w, *gensFlag)
+		case "gif":
+			return g.writeGIF(w, *gensFlag, &gifRecorder{scale: *scaleFlag, delay: *delayFlag, palette: palette})
+		}
+		g.runFor(*gensFlag)
+		return write(w, g, *scaleFlag)
+	}
+	if *outFlag != "-" {
+		// The file only replaces one already at the path once the whole
+		// run has been written.
+		return writeFileSafely(*outFlag, run)
+	}
+	w := bufio.NewWriter(os.Stdout)
+	if err := run(w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// headlessWriter returns what writes the grid out in the given format.
+func headlessWriter(format string) (func(io.Writer, *Grid, int) error, error) {
+	switch format {
+	case "png":
+		return func(w io.Writer, g *Grid, scale int) error {
+			return png.Encode(w, g.snapshotImage(scale))
+		}, nil
+	case "csv", "gif":
+		// Written while running, see writePopulationCSV and writeGIF.
+		return nil, nil
+	case "":
+		format = "rle"
+	}
+
+	f := formatForPath("." + format)
+	if f == nil {
+		return nil, fmt.Errorf("run: unknown format %q", format)
+	}
+	return func(w io.Writer, g *Grid, _ int) error {
+		return f.write(w, g.pattern())
+	}, nil
+}
+
+// runFor advances the grid by n generations. HashLife gets there in one
+// jump per bit set in n.
+func (g *Grid) runFor(n int) {
+	if !g.useHashLife {
+		for i := 0; i < n; i++ {
+			g.step()
+		}
+		return
+	}
+	for j := 0; n>>j != 0; j++ {
+		if n>>j&1 != 0 {
+			g.jump = j
+			g.step()
+		}
+	}
+}
+
+// writePopulationCSV runs the grid for n generations, writing the
+// population, births and deaths of every generation from the current one on
+// in the same form as the statistics panel exports them.
+func (g *Grid) writePopulationCSV(w io.Writer, n int) error {
+	g.jump = 0
+	if err := writeStatsHeader(w); err != nil {
+		return err
+	}
+	for i := 0; ; i++ {
+		if err := writeStatsRow(w, g.sample()); err != nil {
+			return err
+		}
+		if i == n {
+			return nil
+		}
+		g.step()
+	}
+}
+
+// snapshotImage draws the grid with every cell scale pixels wide: the whole
+// board for finite topologies and the cells that are not dead on unbounded
+// ones.
+func (g *Grid) snapshotImage(scale int) *image.RGBA {
+	minX, minY, maxX, maxY := 0, 0, g.cols-1, g.rows-1
+	if g.topology == sim.Unbounded {
+		var ok bool
+		minX, minY, maxX, maxY, ok = g.cells.Bounds()
+		if !ok {
+			minX, minY, maxX, maxY = 0, 0, 0, 0
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, (maxX-minX+1)*scale, (maxY-minY+1)*scale))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	g.cells.ForEachIn(minX, minY, maxX+1, maxY+1, func(x, y int, state uint8) {
+		px, py := (x-minX)*scale, (y-minY)*scale
+		r := image.Rect(px, py, px+scale, py+scale)
+		draw.Draw(img, r, image.NewUniform(g.stateColor(state)), image.Point{}, draw.Src)
+	})
+	return img
+}
@@ -0,0 +1,138 @@
+package main
+
+import "epractice/life/sim"
+
+// history keeps earlier states of the board so that generations can be
+// stepped back through and edits undone. Both share one memory budget; when
+// it is used up the oldest generations are dropped first, then the oldest
+// edits.
+type history struct {
+	budget int
+	used   int
+
+	generations timeline
+	edits       timeline
+}
+
+// timeline is a list of states to go back to and of the states that were
+// gone back from, to go forward to again.
+type timeline struct {
+	back    []snapshot
+	forward []snapshot
+}
+
+// snapshot is a saved state of the board.
+type snapshot struct {
+	cells      sim.Universe
+	generation int
+	size       int
+}
+
+func (h *history) snapshot(cells sim.Universe, generation int) snapshot {
+	s := snapshot{cells: cells.Clone(), generation: generation}
+	s.size = s.cells.MemSize()
+	h.used += s.size
+	return s
+}
+
+// record saves cells as a state to go back to. Going forward is no longer
+// possible once something new has happened.
+func (h *history) record(t *timeline, cells sim.Universe, generation int) {
+	if h.budget <= 0 {
+		return
+	}
+	for _, s := range t.forward {
+		h.used -= s.size
+	}
+	t.forward = nil
+	t.back = append(t.back, h.snapshot(cells, generation))
+	h.trim()
+}
+
+// undo returns the state before the current one on t and keeps the current
+// one to go forward to. ok is false when there is nothing to go back to.
+func (h *history) undo(t *timeline, cells sim.Universe, generation int) (s snapshot, ok bool) {
+	if len(t.back) == 0 {
+		return snapshot{}, false
+	}
+	s = t.back[len(t.back)-1]
+	t.back = t.back[:len(t.back)-1]
+	h.used -= s.size
+	t.forward = append(t.forward, h.snapshot(cells, generation))
+	h.trim()
+	return s, true
+}
+
+// redo is the opposite of undo.
+func (h *history) redo(t *timeline, cells sim.Universe, generation int) (s snapshot, ok bool) {
+	if len(t.forward) == 0 {
+		return snapshot{}, false
+	}
+	s = t.forward[len(t.forward)-1]
+	t.forward = t.forward[:len(t.forward)-1]
+	h.used -= s.size
+	t.back = append(t.back, h.snapshot(cells, generation))
+	h.trim()
+	return s, true
+}
+
+func (h *history) reset() {
+	h.generations = timeline{}
+	h.edits = timeline{}
+	h.used = 0
+}
+
+// trim drops the oldest states until the history fits in its budget.
+func (h *history) trim() {
+	for _, t := range []*timeline{&h.generations, &h.edits} {
+		for h.used > h.budget && len(t.back) > 0 {
+			h.used -= t.back[0].size
+			t.back[0] = snapshot{}
+			t.back = t.back[1:]
+		}
+	}
+}
+
+// edit records the board before a change made by hand so that it can be
+// undone.
+func (g *Grid) edit() {
+	g.history.record(&g.history.edits, g.cells, g.generation)
+	g.cycle.reset()
+	g.changed()
+}
+
+func (g *Grid) undo() {
+	if s, ok := g.history.undo(&g.history.edits, g.cells, g.generation); ok {
+		g.restore(s)
+	}
+}
+
+func (g *Grid) redo() {
+	if s, ok := g.history.redo(&g.history.edits, g.cells, g.generation); ok {
+		g.restore(s)
+	}
+}
+
+// stepBack goes back to the generation before the current one.
+func (g *Grid) stepBack() {
+	if s, ok := g.history.undo(&g.history.generations, g.cells, g.generation); ok {
+		g.restore(s)
+	}
+}
+
+// stepForward goes forward again after stepping back, or steps to a new
+// generation when there is nothing to go forward to.
+func (g *Grid) stepForward() {
+	if s, ok := g.history.redo(&g.history.generations, g.cells, g.generation); ok {
+		g.restore(s)
+		return
+	}
+	g.step()
+}
+
+func (g *Grid) restore(s snapshot) {
+	g.cells = s.cells
+	g.generation = s.generation
+	g.cycle.reset()
+	g.changed()
+}
@@ -0,0 +1,36 @@
+package main
+
+// The screen is laid out around the view: the header above it, the
+// statistics panel to its right and the notice line below it, all with a
+// margin from the edges.
+const (
+	margin = 20
+	// headerLineHeight is the spacing of the status lines in the corners of
+	// the header, the first of which is at margin.
+	headerLineHeight = 14
+	headerLines      = 4
+	headerHeight     = margin + headerLines*headerLineHeight + 4
+	footerHeight     = 2 * margin
+
+	// The smallest window the header and the statistics panel fit in.
+	minScreenWidth  = 800
+	minScreenHeight = 600
+)
+
+// layout fits the view to a screen of the given size.
+func (g *Grid) layout(width, height int) {
+	g.startX, g.startY = margin, headerHeight
+	g.viewWidth = max(width-statsPanelWidth-3*margin, 1)
+	g.viewHeight = max(height-headerHeight-footerHeight, 1)
+}
+
+// headerLineY returns the baseline of the ith status line of the header.
+func headerLineY(i int) int {
+	return margin + i*headerLineHeight
+}
+
+// noticeY returns the baseline of the notice line on a screen of the given
+// height.
+func noticeY(height int) int {
+	return height - footerHeight/2 + 4
+}
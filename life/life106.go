@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Life 1.06 files list the coordinates of every live cell, one cell per
+// line, after a "#Life 1.06" header:
+//
+//	#Life 1.06
+//	0 -1
+//	1 0
+//	-1 1
+//	0 1
+//	1 1
+
+const life106Header = "#Life 1.06"
+
+func readLife106(r io.Reader) (*Pattern, error) {
+	p := &Pattern{}
+	var cells []patternCell
+	minX, minY := 0, 0
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			// Besides the header some files carry #N names and #D
+			// descriptions.
+			switch {
+			case strings.HasPrefix(line, "#N"):
+				p.name = strings.TrimSpace(line[2:])
+			case strings.HasPrefix(line, "#D"):
+				p.comments = append(p.comments, strings.TrimSpace(line[2:]))
+			}
+			continue
+		}
+
+		var c patternCell
+		if _, err := fmt.Sscan(line, &c.x, &c.y); err != nil {
+			return nil, fmt.Errorf("life 1.06: bad cell %q", line)
+		}
+		c.state = 1
+		if len(cells) == 0 {
+			minX, minY = c.x, c.y
+		}
+		minX, minY = min(minX, c.x), min(minY, c.y)
+		cells = append(cells, c)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range cells {
+		cells[i].x -= minX
+		cells[i].y -= minY
+	}
+	if err := p.fromCells(cells); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// writeLife106 writes the live cells of p relative to its centre, which is
+// how Life 1.06 files are usually laid out.
+func writeLife106(w io.Writer, p *Pattern) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, life106Header)
+
+	for y := 0; y < p.height; y++ {
+		for x := 0; x < p.width; x++ {
+			switch p.at(x, y) {
+			case 0:
+			case 1:
+				fmt.Fprintf(bw, "%d %d\n", x-p.width/2, y-p.height/2)
+			default:
+				return errTooManyStates
+			}
+		}
+	}
+
+	return bw.Flush()
+}
@@ -1,176 +1,477 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"image/color"
 	"log"
+	"math"
 	"os"
 	"time"
 
+	"epractice/life/sim"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 	"golang.org/x/image/colornames"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/opentype"
 )
 
-// @Speed: Don't use a struct for Cell. For index should suffice and
-// x, y can be inferred based on the Cell index
-type Cell struct {
-	x int
-	y int
-}
-
 type Grid struct {
-	startX int
-	startY int
-	rows   int
-	cols   int
+	// The board is drawn in the view, a viewWidth x viewHeight area of the
+	// screen with its top left corner at startX, startY.
+	startX     int
+	startY     int
+	viewWidth  int
+	viewHeight int
+
+	// rows and cols are the size of the board for finite topologies.
+	rows int
+	cols int
 
-	cellSize  int
 	edgeWidth int
 
-	// @Speed: Can we just use a bitmap?
-	liveCells map[Cell]bool
+	camera camera
+	// panning is set during a drag that moves the view, and panX, panY is
+	// where the cursor was on its last frame.
+	panning bool
+	panX    int
+	panY    int
+	// paint is the stroke being drawn with the mouse.
+	paint paintStroke
+
+	cells sim.Universe
+
+	rule     sim.Rule
+	topology sim.Topology
+
+	// useHashLife runs unbounded boards with HashLife, advancing 2^jump
+	// generations per step.
+	useHashLife bool
+	jump        int
+
+	history history
 
 	run bool
+
+	// stamp is the pattern a left click stamps, nil when a click toggles a
+	// cell instead.
+	stamp *Pattern
+
+	// selection is the rectangle picked with a shift-drag, selecting is set
+	// while the drag goes on.
+	selection selection
+	selecting bool
+	// clipboard is the last pattern copied, pasted when the system clipboard
+	// has no pattern on it.
+	clipboard *Pattern
+	// fillDensity is the share of cells a random fill brings to life.
+	fillDensity float64
+
+	// speed is an index into speeds. owed is how many steps are due but
+	// not run yet, lastUpdate when they were last worked out.
+	speed      int
+	owed       float64
+	lastUpdate time.Time
+
+	// generation counts the generations run since the board was cleared or
+	// a pattern was loaded.
+	generation int
+	// gensPerSecond is the measured speed, from the generations run since
+	// rateFrom.
+	gensPerSecond  float64
+	rateFrom       time.Time
+	rateGeneration int
+
+	stats stats
+
+	// cycle spots the board repeating itself, autoPause stops the run when
+	// it does.
+	cycle     cycleDetector
+	autoPause bool
+
+	// renderMode is what cells are coloured by. values holds what the
+	// mode needs to know about past generations.
+	renderMode renderMode
+	values     cellValues
+
+	// version goes up whenever the cells change, for the renderer to know
+	// when to draw them again.
+	version  int
+	renderer cellRenderer
+
+	// recording captures every generation while it is not nil. saving
+	// brings the outcome of saving the last recording while that goes on.
+	recording *gifRecorder
+	saving    chan string
+
+	// showObjects outlines and names the known objects on the board.
+	// objects are the objects found at objectsVersion, at objectsAt, and
+	// tooManyCells is set when the board was too busy to look. finding
+	// brings the objects of a search still under way.
+	showObjects    bool
+	objects        []*foundObject
+	finding        chan []*foundObject
+	objectsVersion int
+	objectsAt      time.Time
+	tooManyCells   bool
+
+	// notice is a line of feedback shown under the grid, such as the
+	// result of loading a pattern.
+	notice string
 }
 
+var (
+	gridLineColor     = color.RGBA{101, 107, 117, 255}
+	outsideBoardColor = color.RGBA{30, 32, 36, 255}
+	deadColor         = color.RGBA{0, 0, 0, 255}
+	liveColor         = color.RGBA{255, 255, 255, 255}
+)
+
 func (g *Grid) draw(screen *ebiten.Image) {
-	// Outer
-	// (0, 0), (75, 0), (150, 0), (225, 0)
-	// (0, 75), (75, 75), (150, 75), (225, 75)
-
-	// x * w
-	// y * w
-
-	// Inner
-	// (1, 1), (76, 1), (151, 1), (225, 0)
-	// (1, 76), (75, 75), (150, 75), (225, 75)
-
-	// 1 + x * w
-	// 1 + y * w
-
-	// Draw the Grid
-
-	outer := ebiten.NewImage(g.cellSize, g.cellSize)
-	outer.Fill(color.RGBA{101, 107, 117, 0})
-	inner := ebiten.NewImage(g.cellSize-g.edgeWidth-1, g.cellSize-g.edgeWidth-1)
-	inner.Fill(color.Black)
-
-	// Go through all the cells
-	for r := 0; r < g.rows; r++ {
-		for c := 0; c < g.cols; c++ {
-			op := &ebiten.DrawImageOptions{}
-
-			x := g.startX + r*g.cellSize
-			y := g.startY + c*g.cellSize
-
-			op.GeoM.Translate(float64(x), float64(y))
-			screen.DrawImage(outer, op)
-
-			cell := Cell{r, c}
-			if found := g.liveCells[cell]; found {
-				// Don't draw inner rectangle if this is a live cell.
-				// This allows us to see the live cell as a filled rectangle with white color
-			} else {
-				op2 := &ebiten.DrawImageOptions{}
-				op2.GeoM.Translate(float64(x+g.edgeWidth), float64(y+g.edgeWidth))
-				screen.DrawImage(inner, op2)
-			}
-		}
+	g.drawCells(screen)
+	if g.showObjects {
+		g.drawObjects(screen)
 	}
 }
 
 func (g *Grid) update() {
-	if !g.run {
-		return
+	now := time.Now()
+	g.advance(now)
+	g.measureRate(now)
+	// Edits change the population without a step.
+	g.stats.record(g.sample())
+	g.checkSaved()
+	if g.showObjects {
+		g.refreshObjects(now)
 	}
+}
 
-	timeDelta := time.Since(lastUpdatedTime)
+// maxJump is the largest HashLife step, as a power of two.
+const maxJump = 40
 
-	if timeDelta < time.Millisecond*200 {
-		return
+// step advances the grid by one generation, or by 2^jump generations with
+// HashLife.
+func (g *Grid) step() {
+	g.history.record(&g.history.generations, g.cells, g.generation)
+	if len(g.cycle.seen) == 0 {
+		// The first state after a change is not seen by the check below.
+		g.checkCycle()
+	}
+	g.syncValues()
+
+	if h, ok := g.cells.(*sim.HashLife); ok {
+		h.Jump(g.rule, g.jump)
+		g.generation += 1 << g.jump
 	} else {
-		lastUpdatedTime = time.Now()
+		g.cells.Step(g.rule)
+		g.generation++
+	}
+	g.stats.record(g.sample())
+	g.checkCycle()
+	if g.renderMode != renderStates {
+		g.values.update(g.cells, g.renderMode)
 	}
+	g.changed()
+	g.values.version = g.version
 
-	// @Speed Can we figure out a way not to make a new map everytime?
-	nextGen := make(map[Cell]bool)
+	if g.recording != nil {
+		if err := g.recording.capture(g); err != nil {
+			g.stopRecording()
+			g.notice = "Recording stopped, " + err.Error() + ". " + g.notice
+		}
+	}
+}
 
-	// Go through all the cells
-	for r := 0; r < g.rows; r++ {
-		for c := 0; c < g.cols; c++ {
-			neighbours := getNeighbours(Cell{r, c})
+func (g *Grid) setRule(rule sim.Rule) {
+	if rule.States() != g.rule.States() {
+		// Dying states mean something else under the new rule.
+		g.clearDying()
+	}
+	g.rule = rule
+	g.cycle.reset()
+	g.changed()
+
+	if g.useHashLife && !sim.HashLifeSupports(rule) {
+		g.useHashLife = false
+		g.rebuild()
+		g.notice = "HashLife cannot run " + rule.Label() + ", switched back to the naive engine"
+	}
+}
 
-			liveNeighborCount := 0
-			for i := range neighbours {
-				if g.liveCells[neighbours[i]] {
-					liveNeighborCount++
-				}
-			}
+func (g *Grid) clearDying() {
+	minX, minY, maxX, maxY, ok := g.cells.Bounds()
+	if !ok {
+		return
+	}
 
-			// Apply the rules
-			isAlive := g.liveCells[Cell{r, c}]
-			if isAlive && liveNeighborCount == 2 || liveNeighborCount == 3 {
-				// Cell continues to stay alive don't do anything
-				nextGen[Cell{r, c}] = true
-			} else if !isAlive && liveNeighborCount == 3 {
-				// Cell becomes alive
-				nextGen[Cell{r, c}] = true
-			} else {
-				// Cell becomes dead
-			}
+	var dying [][2]int
+	g.cells.ForEachIn(minX, minY, maxX+1, maxY+1, func(x, y int, state uint8) {
+		if state >= 2 {
+			dying = append(dying, [2]int{x, y})
 		}
+	})
+	for _, c := range dying {
+		g.cells.SetCell(c[0], c[1], 0)
 	}
-	g.liveCells = nextGen
+}
 
+// setTopology switches to topology t, carrying over the cells that fit on
+// the new board.
+func (g *Grid) setTopology(t sim.Topology) {
+	g.topology = t
+	if t != sim.Unbounded {
+		g.useHashLife = false
+	}
+	g.rebuild()
+}
+
+// resize changes the size of finite boards, keeping the cells that still
+// fit.
+func (g *Grid) resize(rows, cols int) {
+	g.rows, g.cols = rows, cols
+	if g.topology != sim.Unbounded {
+		g.rebuild()
+	}
+}
+
+// toggleHashLife switches between HashLife and the naive engine.
+func (g *Grid) toggleHashLife() {
+	switch {
+	case g.useHashLife:
+		g.useHashLife = false
+	case g.topology != sim.Unbounded:
+		g.notice = "HashLife only runs on the unbounded topology"
+		return
+	case !sim.HashLifeSupports(g.rule):
+		g.notice = "HashLife cannot run " + g.rule.Label()
+		return
+	default:
+		g.useHashLife = true
+	}
+	g.rebuild()
+}
+
+// rebuild moves the cells into a new universe that suits the topology and
+// engine.
+func (g *Grid) rebuild() {
+	var cells sim.Universe
+	switch {
+	case g.topology != sim.Unbounded:
+		cells = sim.NewFinite(g.rows, g.cols, g.topology)
+	case g.useHashLife:
+		cells = sim.NewHashLife()
+	default:
+		cells = sim.NewSparse()
+	}
+	if g.cells != nil {
+		sim.CopyCells(cells, g.cells)
+	}
+	g.cells = cells
+
+	// Saved states belong to the old universe.
+	g.history.reset()
+	g.cycle.reset()
+	g.changed()
+}
+
+// engineLabel describes the engine running the grid.
+func (g *Grid) engineLabel() string {
+	if g.useHashLife {
+		return fmt.Sprintf("HashLife, 2^%d gens per step", g.jump)
+	}
+	return "Naive"
+}
+
+// stateColor returns the colour a cell in the given state is drawn in. Dying
+// cells fade from yellow to dark red on their way back to dead.
+func (g *Grid) stateColor(state uint8) color.RGBA {
+	if state == 0 {
+		return deadColor
+	}
+	if state == 1 {
+		return liveColor
+	}
+	t := float64(int(state)-2) / float64(max(g.rule.States()-2, 1))
+	return color.RGBA{
+		R: uint8(255 - 175*t),
+		G: uint8(220 * (1 - t)),
+		B: uint8(60 * (1 - t)),
+		A: 255,
+	}
 }
 
 func (g *Grid) handleKeyEvent(key ebiten.Key) {
+	ctrl := ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyMeta)
+	shift := ebiten.IsKeyPressed(ebiten.KeyShift)
+
 	switch {
+	case key == ebiten.KeyS && ctrl:
+		if err := g.saveSession(sessionPath); err != nil {
+			g.notice = "Save failed: " + err.Error()
+		} else {
+			g.notice = "Saved the session to " + sessionPath
+		}
+	case key == ebiten.KeyO && ctrl:
+		if err := g.loadSession(sessionPath); err != nil {
+			g.notice = "Load failed: " + err.Error()
+		} else {
+			g.notice = "Loaded the session from " + sessionPath
+		}
+	case key == ebiten.KeyO:
+		g.toggleObjects()
+	case key == ebiten.KeyC && ctrl:
+		g.copySelection()
+	case key == ebiten.KeyX && ctrl:
+		g.cutSelection()
+	case key == ebiten.KeyV && ctrl:
+		g.paste()
 	case key == ebiten.KeyC:
-		// Clear the map
-		g.liveCells = make(map[Cell]bool)
+		// Clear the grid
+		g.edit()
+		g.cells.Clear()
+		g.generation = 0
 	case key == ebiten.KeySpace:
 		g.run = !g.run
+	case key == ebiten.KeyN:
+		g.setRule(g.rule.Next())
+	case key == ebiten.KeyT:
+		g.setTopology(g.topology.Next())
+	case key == ebiten.KeyL:
+		g.load(patternPath)
+	case key == ebiten.KeyW:
+		g.save(patternPath)
+	case key == ebiten.KeyE:
+		g.toggleHashLife()
+	case key == ebiten.KeyBracketLeft:
+		g.jump = max(g.jump-1, 0)
+		g.cycle.reset()
+	case key == ebiten.KeyBracketRight:
+		g.jump = min(g.jump+1, maxJump)
+		g.cycle.reset()
+	case key == ebiten.KeyLeft && !g.run:
+		g.stepBack()
+	case key == ebiten.KeyRight && !g.run, key == ebiten.KeyPeriod && !g.run:
+		g.stepForward()
+	case key == ebiten.KeyEqual, key == ebiten.KeyNumpadAdd:
+		g.speed = min(g.speed+1, len(speeds)-1)
+	case key == ebiten.KeyMinus, key == ebiten.KeyNumpadSubtract:
+		g.speed = max(g.speed-1, 0)
+	case key == ebiten.KeyZ && ctrl && shift, key == ebiten.KeyY && ctrl:
+		g.redo()
+	case key == ebiten.KeyZ && ctrl:
+		g.undo()
+	case key == ebiten.KeyR && g.stamp != nil:
+		g.stamp = g.stamp.rotate()
+	case key == ebiten.KeyF && g.stamp != nil:
+		g.stamp = g.stamp.flip()
+	case key == ebiten.KeyDelete, key == ebiten.KeyBackspace:
+		g.clearSelection()
+	case key == ebiten.KeyI:
+		g.invertSelection()
+	case key == ebiten.KeyD:
+		g.randomFillSelection()
+	case key >= ebiten.KeyDigit1 && key <= ebiten.KeyDigit9:
+		g.fillDensity = float64(key-ebiten.KeyDigit0) / 10
+		g.notice = fmt.Sprintf("Random fill density %.0f%%", g.fillDensity*100)
+	case key == ebiten.KeyA:
+		g.autoPause = !g.autoPause
+		if g.autoPause {
+			g.notice = "Pausing when the board stabilises"
+		} else {
+			g.notice = "Not pausing when the board stabilises"
+		}
+	case key == ebiten.KeyM:
+		g.setRenderMode(g.renderMode.next())
+	case key == ebiten.KeyG:
+		g.toggleRecording()
+	case key == ebiten.KeyEscape:
+		g.stamp = nil
+		g.selection = selection{}
+		g.notice = ""
 	}
 }
 
-func (g *Grid) handleMouseEvent(mx, my int) {
-	x := (mx - g.startX) / g.cellSize
-	y := (my - g.startY) / g.cellSize
+func (g *Grid) load(path string) {
+	p, err := loadPattern(path)
+	if err == nil {
+		g.edit()
+		err = g.place(p)
+	}
+	if err != nil {
+		g.notice = "Load failed: " + err.Error()
+		return
+	}
+	g.notice = fmt.Sprintf("Loaded %s (%dx%d)", path, p.width, p.height)
+}
 
-	if x < 0 || x >= g.cols || y < 0 || y >= g.rows {
-		// Out of grid area - Do nothing
+func (g *Grid) save(path string) {
+	if err := savePattern(path, g.pattern()); err != nil {
+		g.notice = "Save failed: " + err.Error()
 		return
 	}
-	c := Cell{x, y}
-	g.liveCells[c] = !g.liveCells[c]
+	g.notice = "Saved " + path
 }
 
-// ---------------- Variables --------------------
-const (
-	screenWidth  = 720
-	screenHeight = 720
-)
+// inView reports whether the screen pixel mx, my is inside the view.
+func (g *Grid) inView(mx, my int) bool {
+	return mx >= g.startX && mx < g.startX+g.viewWidth && my >= g.startY && my < g.startY+g.viewHeight
+}
+
+// handleCamera pans the view while the middle button, or the left one with
+// Ctrl held, is dragged and zooms it with the mouse wheel.
+func (g *Grid) handleCamera(mx, my int) {
+	if !g.paint.active && g.inView(mx, my) && (inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonMiddle) ||
+		inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) && ebiten.IsKeyPressed(ebiten.KeyControl)) {
+		g.panning = true
+		g.panX, g.panY = mx, my
+	}
+	if g.panning {
+		if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonMiddle) && !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			g.panning = false
+		}
+		g.camera.pan(mx-g.panX, my-g.panY)
+		g.panX, g.panY = mx, my
+	}
+
+	if _, wheel := ebiten.Wheel(); wheel != 0 && g.inView(mx, my) {
+		g.camera.zoomAt(mx-g.startX, my-g.startY, math.Pow(1.25, wheel))
+	}
+}
 
+// ---------------- Variables --------------------
 var (
 	start = false
 
-	lastUpdatedTime = time.Now()
-
+	// patternPath is the pattern file loaded with L and saved with W.
+	patternPath = "pattern.rle"
+	// statsPath is the file the population series is exported to with F2.
+	statsPath = "population.csv"
+	// sessionPath is the session file saved with Ctrl+S and loaded with
+	// Ctrl+O.
+	sessionPath = "session.json"
+	// autosaveOnExit saves the session when the window is closed.
+	autosaveOnExit = true
+
+	// recordingPath is the GIF file G records to, with cells recordScale
+	// pixels wide shown for recordDelay each in recordPalette.
+	recordingPath = "life.gif"
+	recordScale   = 4
+	recordDelay   = 100 * time.Millisecond
+	recordPalette = gifPalettes[0]
+
+	// The view and the board are sized from the config when the program
+	// starts.
 	grid = &Grid{
-		startX: 60,
-		startY: 80,
+		edgeWidth: 1,
 
-		rows: 30,
-		cols: 30,
+		rule: sim.Conway,
 
-		cellSize:  20,
-		edgeWidth: 1,
+		speed: defaultSpeed,
 
-		liveCells: make(map[Cell]bool),
+		fillDensity: 0.5,
 	}
 
 	TechnoRaceSmall  font.Face
@@ -179,7 +480,10 @@ var (
 )
 
 // ----------------- Init --------------------
-func init() {
+
+// loadFonts loads the fonts of the window. The run command has no window and
+// does not need them.
+func loadFonts() {
 	fontData, err := os.ReadFile("techno-race.otf")
 	if err != nil {
 		log.Fatal(err)
@@ -222,36 +526,6 @@ func init() {
 
 // ------------- Utils -------------------------
 
-func getNeighbours(c Cell) []Cell {
-	neighbours := []Cell{}
-	for x := -1; x <= 1; x++ {
-		for y := -1; y <= 1; y++ {
-			if x == 0 && y == 0 {
-				continue
-			}
-			neighbours = append(neighbours, Cell{c.x + x, c.y + y})
-		}
-	}
-	return neighbours
-}
-
-// repeatingButtonPressed return true when key is pressed considering the repeat state.
-func repeatingButtonPressed(button ebiten.MouseButton) bool {
-	const (
-		delay    = 30
-		interval = 3
-		Grid
-	)
-	d := inpututil.MouseButtonPressDuration(button)
-	if d == 1 {
-		return true
-	}
-	if d >= delay && (d-delay)%interval == 0 {
-		return true
-	}
-	return false
-}
-
 // repeatingKeyPressed return true when key is pressed considering the repeat state.
 func repeatingKeyPressed(key ebiten.Key) bool {
 	const (
@@ -272,13 +546,38 @@ func repeatingKeyPressed(key ebiten.Key) bool {
 
 type Game struct {
 	pressedKeys []ebiten.Key
+
+	showHelp    bool
+	showPalette bool
+
+	// width and height are the size of the screen, which follows the
+	// window.
+	width  int
+	height int
 }
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
-	return screenWidth, screenHeight
+	g.width, g.height = outsideWidth, outsideHeight
+	grid.layout(g.width, g.height)
+	return g.width, g.height
 }
 
 func (g *Game) Update() error {
+	if ebiten.IsWindowBeingClosed() {
+		if grid.recording != nil {
+			grid.stopRecording()
+		}
+		if grid.saving != nil {
+			log.Print(<-grid.saving)
+		}
+		if autosaveOnExit {
+			if err := grid.autosave(); err != nil {
+				log.Print("autosave: ", err)
+			}
+		}
+		return ebiten.Termination
+	}
+
 	/*
 		g.pressedKeys = inpututil.AppendPressedKeys(g.pressedKeys[:0])
 
@@ -296,11 +595,36 @@ func (g *Game) Update() error {
 	// @Cleanup
 	mx, my := ebiten.CursorPosition()
 
-	if repeatingButtonPressed(ebiten.MouseButtonLeft) {
-		grid.handleMouseEvent(mx, my)
+	grid.handleCamera(mx, my)
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyP) {
+		g.showPalette = !g.showPalette
+	}
+
+	if g.showPalette && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		if i := paletteEntryAt(mx, my); i >= 0 {
+			grid.pickStamp(stampCatalogue[i])
+		}
+		g.showPalette = false
+		return nil
+	}
+
+	if grid.handleSelection(mx, my) {
 		return nil
 	}
 
+	if grid.panning {
+		return nil
+	}
+
+	if grid.stamp != nil && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		grid.stampAt(mx, my)
+		return nil
+	}
+	// Painting goes on while the board runs and keys work, so it does not
+	// return.
+	grid.handlePaint(mx, my)
+
 	if repeatingKeyPressed(ebiten.KeyC) {
 		grid.handleKeyEvent(ebiten.KeyC)
 		return nil
@@ -310,6 +634,54 @@ func (g *Game) Update() error {
 		grid.handleKeyEvent(ebiten.KeySpace)
 	}
 
+	if repeatingKeyPressed(ebiten.KeyN) {
+		grid.handleKeyEvent(ebiten.KeyN)
+	}
+
+	if repeatingKeyPressed(ebiten.KeyT) {
+		grid.handleKeyEvent(ebiten.KeyT)
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyL) {
+		grid.handleKeyEvent(ebiten.KeyL)
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyW) {
+		grid.handleKeyEvent(ebiten.KeyW)
+	}
+
+	for _, key := range []ebiten.Key{ebiten.KeyE, ebiten.KeyR, ebiten.KeyF, ebiten.KeyEscape, ebiten.KeyX, ebiten.KeyV, ebiten.KeyDelete, ebiten.KeyBackspace, ebiten.KeyI, ebiten.KeyD, ebiten.KeyA, ebiten.KeyM, ebiten.KeyS, ebiten.KeyO, ebiten.KeyG} {
+		if inpututil.IsKeyJustPressed(key) {
+			grid.handleKeyEvent(key)
+		}
+	}
+
+	for key := ebiten.KeyDigit1; key <= ebiten.KeyDigit9; key++ {
+		if inpututil.IsKeyJustPressed(key) {
+			grid.handleKeyEvent(key)
+		}
+	}
+
+	for _, key := range []ebiten.Key{ebiten.KeyBracketLeft, ebiten.KeyBracketRight, ebiten.KeyLeft, ebiten.KeyRight, ebiten.KeyPeriod, ebiten.KeyZ, ebiten.KeyY} {
+		if repeatingKeyPressed(key) {
+			grid.handleKeyEvent(key)
+		}
+	}
+
+	for _, key := range []ebiten.Key{ebiten.KeyEqual, ebiten.KeyNumpadAdd, ebiten.KeyMinus, ebiten.KeyNumpadSubtract} {
+		if inpututil.IsKeyJustPressed(key) {
+			grid.handleKeyEvent(key)
+		}
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyF2) {
+		grid.exportStats(statsPath)
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyF1) {
+		g.showHelp = !g.showHelp
+	}
+
 	// @Cleanup: The below code should also probably be moved to grid.update()
 
 	grid.update()
@@ -337,10 +709,20 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	// Background Color
 	// screen.Fill(color.RGBA{255, 255, 255, 0})
 
-	DrawCenteredText(screen, TechnoRaceBig, "GAME OF LIFE", screenWidth/2, 20)
+	// The title is centred over the grid, the statistics panel is to its
+	// right.
+	cx := grid.startX + grid.viewWidth/2
+	DrawCenteredText(screen, TechnoRaceBig, "GAME OF LIFE", cx, margin)
 
 	msg := "Press Space to START or STOP, C to CLEAR"
-	DrawCenteredText(screen, TechnoRaceNormal, msg, screenWidth/2, 50)
+	DrawCenteredText(screen, TechnoRaceNormal, msg, cx, headerHeight-30)
+
+	msg = "F1 to show all keys"
+	DrawCenteredText(screen, TechnoRaceSmall, msg, cx, headerHeight-12)
+
+	// The status lines are in the corners of the header, left aligned on
+	// the left and right aligned on the right.
+	right := g.width - margin
 
 	// Draw Status
 	if grid.run {
@@ -349,16 +731,249 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		msg = "Status:  Stopped"
 	}
 	bounds := text.BoundString(TechnoRaceSmall, msg)
-	text.Draw(screen, msg, TechnoRaceSmall, screenWidth-bounds.Dx()-20, 20, color.White)
+	text.Draw(screen, msg, TechnoRaceSmall, right-bounds.Dx(), headerLineY(0), color.White)
+
+	// Draw Rule
+	msg = "Rule:  " + grid.rule.Label()
+	bounds = text.BoundString(TechnoRaceSmall, msg)
+	text.Draw(screen, msg, TechnoRaceSmall, right-bounds.Dx(), headerLineY(1), color.White)
+
+	// Draw Topology
+	msg = "Topology:  " + grid.topology.String()
+	text.Draw(screen, msg, TechnoRaceSmall, margin, headerLineY(0), color.White)
+
+	// Draw Engine
+	msg = "Engine:  " + grid.engineLabel()
+	text.Draw(screen, msg, TechnoRaceSmall, margin, headerLineY(1), color.White)
+
+	// Draw Generation
+	msg = fmt.Sprintf("Generation:  %d", grid.generation)
+	text.Draw(screen, msg, TechnoRaceSmall, margin, headerLineY(2), color.White)
+
+	// Draw Render Mode
+	msg = "View:  " + grid.renderMode.String()
+	text.Draw(screen, msg, TechnoRaceSmall, margin, headerLineY(3), color.White)
+
+	// Draw Speed
+	msg = fmt.Sprintf("Speed:  %s, %.4g gens/s", grid.speedLabel(), grid.gensPerSecond)
+	bounds = text.BoundString(TechnoRaceSmall, msg)
+	text.Draw(screen, msg, TechnoRaceSmall, right-bounds.Dx(), headerLineY(2), color.White)
+
+	// Draw Stabilisation
+	if msg = grid.cycle.String(); msg != "" {
+		bounds = text.BoundString(TechnoRaceSmall, msg)
+		text.Draw(screen, msg, TechnoRaceSmall, right-bounds.Dx(), headerLineY(3), colornames.Lightskyblue)
+	}
 
 	// Draw the grid
+	mx, my := ebiten.CursorPosition()
 	grid.draw(screen)
+	if grid.recording != nil {
+		msg = fmt.Sprintf("REC  %d frames", len(grid.recording.frames))
+		text.Draw(screen, msg, TechnoRaceSmall, grid.startX+8, grid.startY+16, colornames.Red)
+	}
+	grid.drawSelection(screen)
+	grid.drawStamp(screen, mx, my)
+	drawStats(screen)
+
+	if grid.notice != "" {
+		text.Draw(screen, grid.notice, TechnoRaceSmall, margin, noticeY(g.height), color.White)
+	}
+
+	if g.showPalette {
+		drawPalette(screen, mx, my)
+	}
+	if g.showHelp {
+		drawHelp(screen)
+	}
+}
 
+// keyHelp lists the keys shown by F1.
+var keyHelp = []string{
+	"Space: start or stop",
+	"C: clear",
+	"Left drag: draw, or erase when started on a live cell",
+	"Right drag: erase",
+	"Middle or Ctrl+left drag: pan",
+	"Wheel: zoom",
+	"N: next rule",
+	"T: next topology",
+	"L: load pattern",
+	"W: write pattern",
+	"E: switch between HashLife and the naive engine",
+	"[ and ]: halve or double the HashLife step",
+	"Left and Right: step back or forward while stopped",
+	". : single step while stopped",
+	"+ and -: run faster or slower",
+	"Ctrl+Z: undo",
+	"Ctrl+Y or Ctrl+Shift+Z: redo",
+	"P: pick a stamp, then left click to stamp it",
+	"R and F: rotate or flip the stamp",
+	"Shift+drag: select cells",
+	"Ctrl+C, Ctrl+X and Ctrl+V: copy, cut and paste as RLE",
+	"Delete: clear the selection",
+	"I: invert the selection",
+	"D: fill the selection randomly",
+	"1 to 9: set the random fill density to 10% to 90%",
+	"Esc: put the stamp away and drop the selection",
+	"F2: export the population series as CSV",
+	"A: pause when the board stabilises",
+	"M: colour by state, age, trail or activity",
+	"Ctrl+S and Ctrl+O: save or load the session",
+	"G: record every generation to a GIF, G again to save it",
+	"O: outline and name the objects on the board",
+}
+
+func drawHelp(screen *ebiten.Image) {
+	const lineHeight = 16
+	x, y := grid.startX+20, grid.startY+30
+
+	height := float32(len(keyHelp)*lineHeight + 40)
+	vector.DrawFilledRect(screen, float32(grid.startX), float32(grid.startY), float32(grid.viewWidth), height, color.RGBA{0, 0, 0, 220}, false)
+	for i, line := range keyHelp {
+		text.Draw(screen, line, TechnoRaceSmall, x, y+i*lineHeight, color.White)
+	}
 }
 
 func main() {
-	ebiten.SetWindowSize(screenWidth, screenHeight)
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		if err := runHeadless(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "census" {
+		if err := runCensus(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	ruleFlag := flag.String("rule", sim.Conway.String(), "rule in B/S notation (B36/S23, 23/3) or by name (HighLife)")
+	topologyFlag := flag.String("topology", sim.Unbounded.String(), "what lies past the grid edges: unbounded, bounded, torus, klein or cross")
+	historyFlag := flag.Int("history-mb", 64, "memory in MiB kept for stepping back and undo")
+	patternFlag := flag.String("pattern", "", "pattern `file` to start with (RLE, plaintext or Life 1.06), also used by the load and write keys")
+	workersFlag := flag.Int("workers", sim.Workers(), "goroutines stepping bands of rows of finite boards")
+	sessionFlag := flag.String("session", sessionPath, "session `file` saved with Ctrl+S and loaded with Ctrl+O")
+	autosaveFlag := flag.Bool("autosave", true, "save the session on exit and restore it on the next launch unless a pattern is given")
+	configFlag := flag.String("config", "", "config `file` with the board and window sizes as JSON (default life/config.json in the user config directory)")
+	rowsFlag := flag.Int("rows", defaultConfig.Rows, "rows of finite boards")
+	colsFlag := flag.Int("cols", defaultConfig.Cols, "columns of finite boards")
+	cellSizeFlag := flag.Float64("cell-size", defaultConfig.CellSize, "size of a cell in pixels at the start")
+	widthFlag := flag.Int("width", defaultConfig.Width, "width of the window at the start")
+	heightFlag := flag.Int("height", defaultConfig.Height, "height of the window at the start")
+	gifFlag := flag.String("gif", recordingPath, "GIF `file` the G key records to")
+	gifScaleFlag := flag.Int("gif-scale", recordScale, "size of a cell in pixels in recordings")
+	gifDelayFlag := flag.Duration("gif-delay", recordDelay, "time each generation is shown in recordings")
+	gifPaletteFlag := flag.String("gif-palette", recordPalette.name, "colours of recordings: screen, paper, phosphor, amber or dead and live colours as #rrggbb,#rrggbb")
+	flag.Parse()
+
+	// The config file is read over the defaults and flags given on the
+	// command line override it.
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	path, must := *configFlag, true
+	if path == "" {
+		var err error
+		if path, err = configPath(); err != nil {
+			log.Fatal(err)
+		}
+		must = false
+	}
+	cfg, err := loadConfig(path, must)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if given["rows"] {
+		cfg.Rows = *rowsFlag
+	}
+	if given["cols"] {
+		cfg.Cols = *colsFlag
+	}
+	if given["cell-size"] {
+		cfg.CellSize = *cellSizeFlag
+	}
+	if given["width"] {
+		cfg.Width = *widthFlag
+	}
+	if given["height"] {
+		cfg.Height = *heightFlag
+	}
+	if err := cfg.check(); err != nil {
+		log.Fatal(err)
+	}
+
+	grid.rows, grid.cols = cfg.Rows, cfg.Cols
+	grid.camera.zoom = cfg.CellSize
+	grid.layout(cfg.Width, cfg.Height)
+
+	sim.SetWorkers(*workersFlag)
+	sessionPath = *sessionFlag
+	recordingPath = *gifFlag
+	recordScale, recordDelay = *gifScaleFlag, *gifDelayFlag
+	if recordScale <= 0 {
+		log.Fatal("-gif-scale must be positive")
+	}
+	if recordPalette, err = parseGIFPalette(*gifPaletteFlag); err != nil {
+		log.Fatal(err)
+	}
+	autosaveOnExit = *autosaveFlag
+
+	grid.history.budget = *historyFlag << 20
+
+	restored := false
+	if autosaveOnExit && *patternFlag == "" {
+		var err error
+		if restored, err = grid.restoreAutosave(); err != nil {
+			log.Print("restoring the autosave: ", err)
+		}
+	}
+
+	// A restored session keeps its own board, topology, rule and zoom unless
+	// they are given on the command line.
+	if restored && (given["rows"] || given["cols"]) {
+		grid.resize(cfg.Rows, cfg.Cols)
+	}
+	if restored && given["cell-size"] {
+		grid.camera.zoom = cfg.CellSize
+	}
+
+	if !restored || given["topology"] {
+		topology, err := sim.ParseTopology(*topologyFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		grid.setTopology(topology)
+	}
+
+	if !restored || given["rule"] {
+		rule, err := sim.ParseRule(*ruleFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		grid.setRule(rule)
+	}
+
+	if *patternFlag != "" {
+		patternPath = *patternFlag
+		p, err := loadPattern(patternPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := grid.place(p); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	loadFonts()
+
+	ebiten.SetWindowSize(cfg.Width, cfg.Height)
+	ebiten.SetWindowSizeLimits(minScreenWidth, minScreenHeight, -1, -1)
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
 	ebiten.SetWindowTitle("Game of Life")
+	ebiten.SetWindowClosingHandled(true)
 	g := Game{}
 
 	if err := ebiten.RunGame(&g); err != nil {
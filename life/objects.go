@@ -0,0 +1,431 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"epractice/life/sim"
+)
+
+// objectKind is how an object behaves when left alone.
+type objectKind int
+
+const (
+	stillLife objectKind = iota
+	oscillator
+	spaceship
+	// unstable objects do not come back to their shape within
+	// maxObjectPeriod generations.
+	unstable
+)
+
+var objectKindNames = [...]string{
+	stillLife:  "still life",
+	oscillator: "oscillator",
+	spaceship:  "spaceship",
+	unstable:   "unstable",
+}
+
+func (k objectKind) String() string {
+	return objectKindNames[k]
+}
+
+const (
+	// maxObjectPeriod is the longest period looked for when an object is
+	// run on its own, and maxObjectCells the most cells it may grow to
+	// before it is given up on.
+	maxObjectPeriod = 256
+	maxObjectCells  = 1024
+)
+
+// shapeKey returns a key for the shape of cells that does not depend on
+// where they are.
+func shapeKey(cells []image.Point) string {
+	minX, minY := cells[0].X, cells[0].Y
+	for _, c := range cells {
+		minX, minY = min(minX, c.X), min(minY, c.Y)
+	}
+	norm := make([]image.Point, len(cells))
+	for i, c := range cells {
+		norm[i] = image.Point{c.X - minX, c.Y - minY}
+	}
+	sort.Slice(norm, func(i, j int) bool {
+		if norm[i].Y != norm[j].Y {
+			return norm[i].Y < norm[j].Y
+		}
+		return norm[i].X < norm[j].X
+	})
+	key := make([]byte, 0, 6*len(norm))
+	for _, c := range norm {
+		key = strconv.AppendInt(key, int64(c.X), 10)
+		key = append(key, ',')
+		key = strconv.AppendInt(key, int64(c.Y), 10)
+		key = append(key, ';')
+	}
+	return string(key)
+}
+
+// symmetries are the eight ways to rotate and reflect a shape, as maps of a
+// cell.
+var symmetries = [8]func(image.Point) image.Point{
+	func(p image.Point) image.Point { return image.Point{p.X, p.Y} },
+	func(p image.Point) image.Point { return image.Point{-p.Y, p.X} },
+	func(p image.Point) image.Point { return image.Point{-p.X, -p.Y} },
+	func(p image.Point) image.Point { return image.Point{p.Y, -p.X} },
+	func(p image.Point) image.Point { return image.Point{-p.X, p.Y} },
+	func(p image.Point) image.Point { return image.Point{p.Y, p.X} },
+	func(p image.Point) image.Point { return image.Point{p.X, -p.Y} },
+	func(p image.Point) image.Point { return image.Point{-p.Y, -p.X} },
+}
+
+// canonicalKey returns a key for the shape of cells that is the same
+// however the shape is moved, rotated or reflected.
+func canonicalKey(cells []image.Point) string {
+	best := ""
+	moved := make([]image.Point, len(cells))
+	for i, sym := range symmetries {
+		for j, c := range cells {
+			moved[j] = sym(c)
+		}
+		if k := shapeKey(moved); i == 0 || k < best {
+			best = k
+		}
+	}
+	return best
+}
+
+// liveCells returns the live cells of u.
+func liveCells(u sim.Universe) []image.Point {
+	var cells []image.Point
+	if minX, minY, maxX, maxY, ok := u.Bounds(); ok {
+		u.ForEachIn(minX, minY, maxX+1, maxY+1, func(x, y int, state uint8) {
+			if state == 1 {
+				cells = append(cells, image.Point{x, y})
+			}
+		})
+	}
+	return cells
+}
+
+// shapeFacts is what running a shape on its own found out about it.
+type shapeFacts struct {
+	period int
+	move   image.Point
+	// key is the objectKey of the shape, empty until it is asked for.
+	key string
+}
+
+// shapeFactsKey is a shape, by its shapeKey, and the rule it was run under.
+type shapeFactsKey struct {
+	rule  string
+	shape string
+}
+
+// maxShapeFacts is how many shapes shapeMemo holds before it starts over.
+const maxShapeFacts = 1 << 14
+
+// shapeMemo remembers the facts of the shapes that come back, as the same
+// objects turn up on a board or in a census again and again. It is used
+// from the census and from the object overlay, which finds objects on a
+// goroutine of its own.
+var shapeMemo = struct {
+	sync.Mutex
+	facts map[shapeFactsKey]shapeFacts
+}{facts: make(map[shapeFactsKey]shapeFacts)}
+
+func lookUpShape(k shapeFactsKey) (shapeFacts, bool) {
+	shapeMemo.Lock()
+	defer shapeMemo.Unlock()
+	f, ok := shapeMemo.facts[k]
+	return f, ok
+}
+
+func rememberShape(k shapeFactsKey, f shapeFacts) {
+	shapeMemo.Lock()
+	defer shapeMemo.Unlock()
+	if len(shapeMemo.facts) >= maxShapeFacts {
+		shapeMemo.facts = make(map[shapeFactsKey]shapeFacts)
+	}
+	shapeMemo.facts[k] = f
+}
+
+// objectMotion runs cells on their own under rule until they come back to
+// their shape. It returns the period and how far the shape moved in it, with
+// ok false when it does not come back.
+func objectMotion(cells []image.Point, rule sim.Rule) (period int, move image.Point, ok bool) {
+	start := shapeKey(cells)
+	k := shapeFactsKey{rule.String(), start}
+	if f, ok := lookUpShape(k); ok {
+		return f.period, f.move, true
+	}
+
+	u := sim.NewSparse()
+	for _, c := range cells {
+		u.SetCell(c.X, c.Y, 1)
+	}
+	corner := minCorner(cells)
+	for t := 1; t <= maxObjectPeriod; t++ {
+		u.Step(rule)
+		if n := u.Population(); n == 0 || n > maxObjectCells {
+			return 0, image.Point{}, false
+		}
+		now := liveCells(u)
+		if shapeKey(now) == start {
+			move := minCorner(now).Sub(corner)
+			rememberShape(k, shapeFacts{period: t, move: move})
+			return t, move, true
+		}
+	}
+	return 0, image.Point{}, false
+}
+
+// objectKey returns a key for an object with the given period that is the
+// same in every phase of it, however it is moved, rotated or reflected.
+func objectKey(cells []image.Point, rule sim.Rule, period int) string {
+	k := shapeFactsKey{rule.String(), shapeKey(cells)}
+	f, known := lookUpShape(k)
+	if known && f.key != "" {
+		return f.key
+	}
+
+	key := canonicalKey(cells)
+	u := sim.NewSparse()
+	for _, c := range cells {
+		u.SetCell(c.X, c.Y, 1)
+	}
+	for t := 1; t < period; t++ {
+		u.Step(rule)
+		key = min(key, canonicalKey(liveCells(u)))
+	}
+	if known {
+		f.key = key
+		rememberShape(k, f)
+	}
+	return key
+}
+
+func minCorner(cells []image.Point) image.Point {
+	m := cells[0]
+	for _, c := range cells {
+		m.X, m.Y = min(m.X, c.X), min(m.Y, c.Y)
+	}
+	return m
+}
+
+// knownObject is an object with a name, found by the canonical key of any
+// of its phases.
+type knownObject struct {
+	name   string
+	kind   objectKind
+	period int
+}
+
+// debrisSeeds are common leftovers of soups that are not worth a stamp of
+// their own. The stamps that are objects are named as well.
+var debrisSeeds = []string{
+	"#N Ship\nx = 3, y = 3\n2o$obo$b2o!",
+	"#N Tub\nx = 3, y = 3\nbo$obo$bo!",
+	"#N Pond\nx = 4, y = 4\nb2o$o2bo$o2bo$b2o!",
+	"#N Long boat\nx = 4, y = 4\n2o$obo$bobo$2bo!",
+	"#N Barge\nx = 4, y = 4\nbo$obo$bobo$2bo!",
+	"#N Mango\nx = 4, y = 4\nb2o$o2bo$bo2bo$2b2o!",
+	"#N Eater\nx = 4, y = 4\n2o$obo$2bo$2b2o!",
+	"#N Snake\nx = 4, y = 2\n2obo$ob2o!",
+	"#N Aircraft carrier\nx = 4, y = 3\n2o$o2bo$2b2o!",
+	"#N Long barge\nx = 5, y = 5\nbo$obo$bobo$2bobo$3bo!",
+	"#N Integral sign\nx = 4, y = 5\n2o$obo$2bo$2bobo$3b2o!",
+}
+
+// objectCatalogue maps the canonical keys of every phase of the named
+// objects under Conway's rule to the object.
+var objectCatalogue = buildObjectCatalogue()
+
+func buildObjectCatalogue() map[string]*knownObject {
+	seeds := append([]*Pattern(nil), stampCatalogue...)
+	for _, s := range debrisSeeds {
+		p, err := readRLE(strings.NewReader(s))
+		if err != nil {
+			panic(fmt.Sprintf("%q: %v", s, err))
+		}
+		seeds = append(seeds, p)
+	}
+
+	catalogue := make(map[string]*knownObject)
+	for _, p := range seeds {
+		var cells []image.Point
+		for y := 0; y < p.height; y++ {
+			for x := 0; x < p.width; x++ {
+				if p.at(x, y) == 1 {
+					cells = append(cells, image.Point{x, y})
+				}
+			}
+		}
+		period, move, ok := objectMotion(cells, sim.Conway)
+		if !ok {
+			// Guns and methuselahs are not objects.
+			continue
+		}
+		o := &knownObject{name: p.name, kind: kindOf(period, move), period: period}
+
+		u := sim.NewSparse()
+		for _, c := range cells {
+			u.SetCell(c.X, c.Y, 1)
+		}
+		for t := 0; t < period; t++ {
+			catalogue[canonicalKey(liveCells(u))] = o
+			u.Step(sim.Conway)
+		}
+	}
+	return catalogue
+}
+
+func kindOf(period int, move image.Point) objectKind {
+	switch {
+	case move != image.Point{}:
+		return spaceship
+	case period == 1:
+		return stillLife
+	}
+	return oscillator
+}
+
+// foundObject is an object found on the board.
+type foundObject struct {
+	// cells are the live cells of the object. On boards that wrap they
+	// are unwrapped, so they may lie past the edges but stay together.
+	cells []image.Point
+	// key is the same for every phase and orientation of the object.
+	key  string
+	name string
+	kind objectKind
+	// period is how long the object takes to come back to its shape, and
+	// move how far it has moved by then.
+	period int
+	move   image.Point
+}
+
+// label is the name of the object, or a description of it in the style of
+// apgsearch when it has none: xs and the number of cells for still lifes, xp
+// and the period for oscillators and xq and the period for spaceships. A
+// short hash of the key tells apart objects that would read the same.
+func (o *foundObject) label() string {
+	if o.name != "" {
+		return o.name
+	}
+	h := fnv.New32a()
+	h.Write([]byte(o.key))
+	id := h.Sum32() & 0xffff
+	switch o.kind {
+	case stillLife:
+		return fmt.Sprintf("xs%d #%04x", len(o.cells), id)
+	case oscillator:
+		return fmt.Sprintf("xp%d #%04x", o.period, id)
+	case spaceship:
+		return fmt.Sprintf("xq%d #%04x", o.period, id)
+	}
+	return fmt.Sprintf("unstable, %d cells #%04x", len(o.cells), id)
+}
+
+// maxJoinReach is how far apart the parts of an object may be.
+const maxJoinReach = 3
+
+// findObjects splits the live cells of u into objects and classifies them.
+// Cells that touch belong to the same object. Groups of cells that do not
+// keep their shape on their own are joined with the cells near them, two and
+// then three cells away, as the separate parts of objects such as the pulsar
+// are.
+func findObjects(u sim.Universe, topology sim.Topology, rows, cols int, rule sim.Rule) []*foundObject {
+	wrap := func(p image.Point) (image.Point, bool) {
+		if topology == sim.Unbounded {
+			return p, true
+		}
+		x, y, ok := topology.Wrap(p.X, p.Y, rows, cols)
+		return image.Point{x, y}, ok
+	}
+
+	// island holds the group each live cell was put in by the last split.
+	island := make(map[image.Point]int)
+	for _, c := range liveCells(u) {
+		island[c] = -1
+	}
+
+	// split puts the live cells into groups of cells joined to the cells
+	// around them, and to the cells up to reach away when join says so for
+	// the islands of the two. The cells of each group come back unwrapped.
+	split := func(reach int, join func(a, b int) bool) [][]image.Point {
+		var groups [][]image.Point
+		labels := make(map[image.Point]int, len(island))
+		for start := range island {
+			if _, done := labels[start]; done {
+				continue
+			}
+			id := len(groups)
+			labels[start] = id
+			group := []image.Point{start}
+			for i := 0; i < len(group); i++ {
+				c := group[i]
+				cw, _ := wrap(c)
+				for dy := -reach; dy <= reach; dy++ {
+					for dx := -reach; dx <= reach; dx++ {
+						n := c.Add(image.Point{dx, dy})
+						w, ok := wrap(n)
+						if !ok {
+							continue
+						}
+						if _, live := island[w]; !live {
+							continue
+						}
+						if _, done := labels[w]; done {
+							continue
+						}
+						if max(abs(dx), abs(dy)) > 1 && !join(island[cw], island[w]) {
+							continue
+						}
+						labels[w] = id
+						group = append(group, n)
+					}
+				}
+			}
+			groups = append(groups, group)
+		}
+		return groups
+	}
+
+	groups := split(1, nil)
+	for reach := 2; reach <= maxJoinReach; reach++ {
+		steady := make([]bool, len(groups))
+		all := true
+		for i, cells := range groups {
+			_, _, steady[i] = objectMotion(cells, rule)
+			all = all && steady[i]
+			for _, c := range cells {
+				w, _ := wrap(c)
+				island[w] = i
+			}
+		}
+		if all {
+			break
+		}
+		groups = split(reach, func(a, b int) bool { return !steady[a] || !steady[b] })
+	}
+
+	conway := rule.String() == sim.Conway.String()
+	objects := make([]*foundObject, len(groups))
+	for i, cells := range groups {
+		o := &foundObject{cells: cells, key: canonicalKey(cells), kind: unstable}
+		if known := objectCatalogue[o.key]; conway && known != nil {
+			o.name = known.name
+		}
+		if period, move, ok := objectMotion(cells, rule); ok {
+			o.kind, o.period, o.move = kindOf(period, move), period, move
+			o.key = objectKey(cells, rule, period)
+		}
+		objects[i] = o
+	}
+	return objects
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"image"
+	"testing"
+
+	"epractice/life/sim"
+)
+
+// stampCells returns the live cells of the stamp with the given name.
+func stampCells(t *testing.T, name string) []image.Point {
+	t.Helper()
+	for _, p := range stampCatalogue {
+		if p.name != name {
+			continue
+		}
+		var cells []image.Point
+		for y := 0; y < p.height; y++ {
+			for x := 0; x < p.width; x++ {
+				if p.at(x, y) == 1 {
+					cells = append(cells, image.Point{x, y})
+				}
+			}
+		}
+		return cells
+	}
+	t.Fatalf("no stamp %q", name)
+	return nil
+}
+
+// onlyObject finds the objects among cells on an unbounded board and
+// returns the one there should be.
+func onlyObject(t *testing.T, cells []image.Point) *foundObject {
+	t.Helper()
+	u := sim.NewSparse()
+	for _, c := range cells {
+		u.SetCell(c.X, c.Y, 1)
+	}
+	objects := findObjects(u, sim.Unbounded, 0, 0, sim.Conway)
+	if len(objects) != 1 {
+		t.Fatalf("found %d objects, want 1", len(objects))
+	}
+	return objects[0]
+}
+
+// TestGliderInEveryOrientationAndPhase turns and reflects every phase of
+// the glider and checks that each is known as the same glider, heading the
+// way it was turned.
+func TestGliderInEveryOrientationAndPhase(t *testing.T) {
+	phase := sim.NewSparse()
+	for _, c := range stampCells(t, "Glider") {
+		phase.SetCell(c.X, c.Y, 1)
+	}
+	first := onlyObject(t, liveCells(phase))
+	if first.kind != spaceship || first.period != 4 {
+		t.Fatalf("glider is a %s of period %d", first.kind, first.period)
+	}
+
+	headings := make(map[string]bool)
+	for p := 0; p < 4; p++ {
+		for i, sym := range symmetries {
+			var cells []image.Point
+			for _, c := range liveCells(phase) {
+				cells = append(cells, sym(c))
+			}
+			o := onlyObject(t, cells)
+			want := heading(sym(first.move))
+			if o.name != "Glider" || o.key != first.key || heading(o.move) != want {
+				t.Errorf("phase %d, symmetry %d: %q heading %s, want Glider heading %s with the key of phase 0",
+					p, i, o.label(), heading(o.move), want)
+			}
+			headings[heading(o.move)] = true
+		}
+		phase.Step(sim.Conway)
+	}
+	if len(headings) != 4 {
+		t.Errorf("gliders headed %v, want all four diagonals", headings)
+	}
+}
+
+// TestPulsarIsOneObject checks that the four quadrants of the pulsar, which
+// do not touch, are joined into one object in every phase.
+func TestPulsarIsOneObject(t *testing.T) {
+	u := sim.NewSparse()
+	for _, c := range stampCells(t, "Pulsar") {
+		u.SetCell(c.X, c.Y, 1)
+	}
+	for p := 0; p < 3; p++ {
+		o := onlyObject(t, liveCells(u))
+		if o.name != "Pulsar" || o.kind != oscillator || o.period != 3 {
+			t.Errorf("phase %d: %q, a %s of period %d", p, o.label(), o.kind, o.period)
+		}
+		u.Step(sim.Conway)
+	}
+}
+
+// TestObjectAcrossTorusEdge puts a glider across the corner of a torus and
+// checks that it is found whole, with its cells unwrapped.
+func TestObjectAcrossTorusEdge(t *testing.T) {
+	const size = 20
+	u := sim.NewFinite(size, size, sim.Torus)
+	for _, c := range stampCells(t, "Glider") {
+		u.SetCell((c.X+size-1)%size, (c.Y+size-2)%size, 1)
+	}
+	objects := findObjects(u, sim.Torus, size, size, sim.Conway)
+	if len(objects) != 1 {
+		t.Fatalf("found %d objects, want 1", len(objects))
+	}
+	o := objects[0]
+	if o.name != "Glider" || len(o.cells) != 5 {
+		t.Fatalf("found %q with %d cells, want Glider with 5", o.label(), len(o.cells))
+	}
+	box := image.Rectangle{Min: minCorner(o.cells), Max: minCorner(o.cells)}
+	for _, c := range o.cells {
+		box = box.Union(image.Rectangle{Min: c, Max: c.Add(image.Point{1, 1})})
+	}
+	if box.Dx() != 3 || box.Dy() != 3 {
+		t.Errorf("cells %v span %v, want 3x3", o.cells, box)
+	}
+}
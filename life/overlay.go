@@ -0,0 +1,139 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+
+	"epractice/life/sim"
+)
+
+const (
+	// objectRefresh is how often the objects on a changing board are
+	// found again.
+	objectRefresh = 250 * time.Millisecond
+	// maxOverlayCells is the most live cells the overlay looks for objects
+	// among, as finding them takes running every group of cells on its own.
+	maxOverlayCells = 4000
+)
+
+var objectColors = [...]color.RGBA{
+	stillLife:  {90, 220, 120, 255},
+	oscillator: {250, 210, 70, 255},
+	spaceship:  {240, 110, 240, 255},
+	unstable:   {170, 170, 170, 255},
+}
+
+// headings names the directions a spaceship can move in, by the signs of
+// how far it moves down and right. y grows down, so up is north.
+var headings = [3][3]string{
+	{"NW", "N", "NE"},
+	{"W", "", "E"},
+	{"SW", "S", "SE"},
+}
+
+func heading(move image.Point) string {
+	sign := func(n int) int {
+		switch {
+		case n < 0:
+			return 0
+		case n > 0:
+			return 2
+		}
+		return 1
+	}
+	return headings[sign(move.Y)][sign(move.X)]
+}
+
+// toggleObjects shows or hides the outlines and names of the objects on the
+// board.
+func (g *Grid) toggleObjects() {
+	g.showObjects = !g.showObjects
+	g.objects = nil
+	g.objectsAt = time.Time{}
+	g.finding = nil
+	switch {
+	case !g.showObjects:
+		g.notice = "Stopped outlining objects"
+	case g.rule.String() != sim.Conway.String():
+		g.notice = "Objects are only named under Conway's rule"
+	default:
+		g.notice = "Outlining objects, O to stop"
+	}
+}
+
+// refreshObjects finds the objects on the board again when the cells have
+// changed, at most once every objectRefresh. Finding them can take a while
+// on a busy board, so it runs on a goroutine of its own, on a copy of the
+// cells, and the objects found so far stay on screen until it is done.
+func (g *Grid) refreshObjects(now time.Time) {
+	if g.finding != nil {
+		select {
+		case g.objects = <-g.finding:
+			g.finding = nil
+		default:
+			return
+		}
+	}
+	if !g.objectsAt.IsZero() && (g.objectsVersion == g.version || now.Sub(g.objectsAt) < objectRefresh) {
+		return
+	}
+	g.objectsAt, g.objectsVersion = now, g.version
+
+	g.tooManyCells = g.cells.Population() > maxOverlayCells
+	if g.tooManyCells {
+		g.objects = nil
+		return
+	}
+	cells := sim.NewSparse()
+	sim.CopyCells(cells, g.cells)
+	topology, rows, cols, rule := g.topology, g.rows, g.cols, g.rule
+	found := make(chan []*foundObject, 1)
+	g.finding = found
+	go func() {
+		found <- findObjects(cells, topology, rows, cols, rule)
+	}()
+}
+
+// drawObjects draws a box around every object in the view, coloured by its
+// kind and labelled with its name, or a description when it has none, and
+// for spaceships where it is heading.
+func (g *Grid) drawObjects(screen *ebiten.Image) {
+	view := screen.SubImage(image.Rect(g.startX, g.startY, g.startX+g.viewWidth, g.startY+g.viewHeight)).(*ebiten.Image)
+	if g.tooManyCells {
+		text.Draw(view, "Too many cells to find objects", TechnoRaceSmall, g.startX+8, g.startY+g.viewHeight-8, color.White)
+		return
+	}
+
+	for _, o := range g.objects {
+		corner := minCorner(o.cells)
+		box := image.Rectangle{Min: corner, Max: corner}
+		for _, c := range o.cells {
+			box.Max.X, box.Max.Y = max(box.Max.X, c.X+1), max(box.Max.Y, c.Y+1)
+		}
+		left, top := g.camera.toView(box.Min.X, box.Min.Y)
+		right, bottom := g.camera.toView(box.Max.X, box.Max.Y)
+		if right < 0 || bottom < 0 || left > float64(g.viewWidth) || top > float64(g.viewHeight) {
+			continue
+		}
+
+		clr := objectColors[o.kind]
+		sx, sy := float32(g.startX)+float32(left), float32(g.startY)+float32(top)
+		vector.StrokeRect(view, sx-2, sy-2, float32(right-left)+4, float32(bottom-top)+4, 1, clr, false)
+
+		label := o.label()
+		if o.kind == spaceship {
+			label += " heading " + heading(o.move)
+		}
+		y := int(sy) - 6
+		if y < g.startY+12 {
+			// No room above the box.
+			y = int(sy) + int(bottom-top) + 14
+		}
+		text.Draw(view, label, TechnoRaceSmall, int(sx), y, clr)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// paintStroke is a drag that draws or erases cells. Whether it draws is
+// decided by the cell it starts on, so a stroke never turns back cells it
+// has already painted.
+type paintStroke struct {
+	active bool
+	button ebiten.MouseButton
+	// state is what the stroke sets cells to.
+	state uint8
+	// x, y is the cell the cursor was over on the last frame, when inside
+	// is set. The stroke paints the line from there to the cell under the
+	// cursor, so a fast drag leaves no gaps.
+	inside bool
+	x, y   int
+}
+
+// handlePaint draws with the left button and erases with the right. A left
+// stroke that starts on a live cell erases instead.
+func (g *Grid) handlePaint(mx, my int) {
+	if !g.paint.active {
+		var button ebiten.MouseButton
+		switch {
+		case g.stamp == nil && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft):
+			button = ebiten.MouseButtonLeft
+		case inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight):
+			button = ebiten.MouseButtonRight
+		default:
+			return
+		}
+		if !g.inView(mx, my) {
+			return
+		}
+
+		x, y := g.camera.toWorld(mx-g.startX, my-g.startY)
+		state := uint8(0)
+		if button == ebiten.MouseButtonLeft && g.cells.Cell(x, y) != 1 {
+			state = 1
+		}
+		// The whole stroke is undone at once.
+		g.edit()
+		g.paint = paintStroke{active: true, button: button, state: state, inside: true, x: x, y: y}
+		g.cells.SetCell(x, y, state)
+		return
+	}
+
+	if !ebiten.IsMouseButtonPressed(g.paint.button) {
+		g.paint.active = false
+		return
+	}
+	if !g.inView(mx, my) {
+		g.paint.inside = false
+		return
+	}
+
+	x, y := g.camera.toWorld(mx-g.startX, my-g.startY)
+	if g.paint.inside && x == g.paint.x && y == g.paint.y {
+		return
+	}
+	if !g.paint.inside {
+		// The stroke comes back into the view here rather than being drawn
+		// across from where it left.
+		g.paint.x, g.paint.y = x, y
+	}
+	forEachOnLine(g.paint.x, g.paint.y, x, y, func(x, y int) {
+		g.cells.SetCell(x, y, g.paint.state)
+	})
+	g.paint.inside, g.paint.x, g.paint.y = true, x, y
+	g.cycle.reset()
+	g.changed()
+}
+
+// forEachOnLine calls fn for every cell on the line from x0, y0 to x1, y1,
+// both ends included, stepping to a neighbouring cell each time.
+func forEachOnLine(x0, y0, x1, y1 int, fn func(x, y int)) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	for e := dx + dy; ; {
+		fn(x0, y0)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"epractice/life/sim"
+)
+
+// Pattern is a rectangle of cells as stored in a pattern file. cells holds
+// the state of every cell row by row, with the same meaning as Grid.cell.
+type Pattern struct {
+	name     string
+	comments []string
+	// rule is the rule the file asks for, empty when it does not say.
+	rule string
+
+	width  int
+	height int
+	cells  []uint8
+}
+
+func newPattern(width, height int) *Pattern {
+	return &Pattern{
+		width:  width,
+		height: height,
+		cells:  make([]uint8, width*height),
+	}
+}
+
+func (p *Pattern) at(x, y int) uint8 {
+	if x < 0 || x >= p.width || y < 0 || y >= p.height {
+		return 0
+	}
+	return p.cells[y*p.width+x]
+}
+
+func (p *Pattern) set(x, y int, state uint8) {
+	if x < 0 || x >= p.width || y < 0 || y >= p.height {
+		return
+	}
+	p.cells[y*p.width+x] = state
+}
+
+// rotate returns p turned a quarter turn clockwise.
+func (p *Pattern) rotate() *Pattern {
+	r := newPattern(p.height, p.width)
+	r.name, r.comments, r.rule = p.name, p.comments, p.rule
+	for y := 0; y < p.height; y++ {
+		for x := 0; x < p.width; x++ {
+			r.set(p.height-1-y, x, p.at(x, y))
+		}
+	}
+	return r
+}
+
+// flip returns p mirrored left to right.
+func (p *Pattern) flip() *Pattern {
+	f := newPattern(p.width, p.height)
+	f.name, f.comments, f.rule = p.name, p.comments, p.rule
+	for y := 0; y < p.height; y++ {
+		for x := 0; x < p.width; x++ {
+			f.set(p.width-1-x, y, p.at(x, y))
+		}
+	}
+	return f
+}
+
+// patternCell is a cell of a pattern that is being read before its size is
+// known.
+type patternCell struct {
+	x, y  int
+	state uint8
+}
+
+const (
+	// maxPatternSide is the widest and tallest a pattern read from a file
+	// may be, and maxPatternArea the most cells it may cover, so that a bad
+	// or hostile file is refused before memory is set aside for it.
+	maxPatternSide = 1 << 16
+	maxPatternArea = 1 << 26
+)
+
+// fromCells sets the cells of p from a list, growing p so that all of them
+// fit when the file header gave a smaller size or none at all.
+// p is left as it was when the cells do not fit in a pattern.
+func (p *Pattern) fromCells(cells []patternCell) error {
+	width, height := p.width, p.height
+	for _, c := range cells {
+		width = max(width, c.x+1)
+		height = max(height, c.y+1)
+	}
+	if width > maxPatternSide || height > maxPatternSide || width*height > maxPatternArea {
+		return fmt.Errorf("pattern of %dx%d cells is too large", width, height)
+	}
+	p.width, p.height = width, height
+	p.cells = make([]uint8, p.width*p.height)
+	for _, c := range cells {
+		p.set(c.x, c.y, c.state)
+	}
+	return nil
+}
+
+// patternFormat is a pattern file format.
+type patternFormat struct {
+	name       string
+	extensions []string
+	read       func(io.Reader) (*Pattern, error)
+	write      func(io.Writer, *Pattern) error
+}
+
+var (
+	rleFormat       = &patternFormat{"RLE", []string{".rle"}, readRLE, writeRLE}
+	plaintextFormat = &patternFormat{"plaintext", []string{".cells", ".txt"}, readPlaintext, writePlaintext}
+	life106Format   = &patternFormat{"Life 1.06", []string{".lif", ".life", ".06"}, readLife106, writeLife106}
+
+	patternFormats = []*patternFormat{rleFormat, plaintextFormat, life106Format}
+)
+
+// formatForPath picks a format by the extension of path, or returns nil when
+// the extension is not a known one.
+func formatForPath(path string) *patternFormat {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, f := range patternFormats {
+		for _, e := range f.extensions {
+			if e == ext {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// sniffFormat guesses the format of a pattern file from its contents. Only
+// the start of the file is looked at: the header or comment lines of each
+// format, or failing those the first line of cells.
+func sniffFormat(data []byte) *patternFormat {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, life106Header):
+			return life106Format
+		case strings.HasPrefix(line, "#"):
+			// RLE comments. Life 1.06 files must start with their header.
+			return rleFormat
+		case strings.HasPrefix(line, "!"):
+			return plaintextFormat
+		case strings.HasPrefix(line, "x"):
+			return rleFormat
+		case strings.Trim(line, ".O*") == "":
+			return plaintextFormat
+		case strings.Trim(line, "-0123456789 \t") == "":
+			return life106Format
+		}
+		return rleFormat
+	}
+	return rleFormat
+}
+
+// loadPattern reads the pattern file at path. Its format is taken from the
+// extension and guessed from the contents when the extension is unknown.
+func loadPattern(path string) (*Pattern, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	format := formatForPath(path)
+	if format == nil {
+		format = sniffFormat(data)
+	}
+
+	p, err := format.read(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// savePattern writes p to the file at path in the format its extension asks
+// for, RLE when the extension is unknown.
+func savePattern(path string, p *Pattern) error {
+	format := formatForPath(path)
+	if format == nil {
+		format = rleFormat
+	}
+
+	return writeFileSafely(path, func(w io.Writer) error {
+		if err := format.write(w, p); err != nil {
+			return fmt.Errorf("%s: %s %w", path, format.name, err)
+		}
+		return nil
+	})
+}
+
+// writeFileSafely writes the file at path with write, first to a temporary
+// file next to it that then takes its place. A file already at path is left
+// as it was when write fails.
+func writeFileSafely(path string, write func(io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+	err = write(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Chmod(0o644)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// place replaces the cells of the grid with p and switches to the rule of
+// the pattern when it has one. The pattern goes in the middle of finite
+// boards and in the middle of the view on unbounded ones.
+func (g *Grid) place(p *Pattern) error {
+	if p.rule != "" {
+		rule, err := sim.ParseRule(p.rule)
+		if err != nil {
+			return err
+		}
+		g.setRule(rule)
+	}
+
+	g.cells.Clear()
+	g.generation = 0
+	g.cycle.reset()
+	g.changed()
+
+	cx, cy := g.cols/2, g.rows/2
+	if g.topology == sim.Unbounded {
+		cx, cy = g.camera.toWorld(g.viewWidth/2, g.viewHeight/2)
+	}
+	offX, offY := cx-p.width/2, cy-p.height/2
+	for y := 0; y < p.height; y++ {
+		for x := 0; x < p.width; x++ {
+			if s := p.at(x, y); s != 0 {
+				g.cells.SetCell(offX+x, offY+y, s)
+			}
+		}
+	}
+	return nil
+}
+
+// pattern returns the smallest rectangle of the grid holding every cell that
+// is not dead.
+func (g *Grid) pattern() *Pattern {
+	minX, minY, maxX, maxY, ok := g.cells.Bounds()
+	if !ok {
+		p := newPattern(0, 0)
+		p.rule = g.rule.String()
+		return p
+	}
+
+	return g.region(minX, minY, maxX+1, maxY+1)
+}
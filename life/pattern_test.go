@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadersRejectOversizedPatterns(t *testing.T) {
+	tests := []struct {
+		name  string
+		read  func(string) (*Pattern, error)
+		input string
+	}{
+		{"life 1.06 wide", readLife106String, "#Life 1.06\n0 0\n100000 0\n"},
+		{"life 1.06 tall", readLife106String, "#Life 1.06\n0 -70000\n0 0\n"},
+		{"life 1.06 far apart", readLife106String, "#Life 1.06\n-2000000000 0\n2000000000 0\n"},
+		{"plaintext wide", readPlaintextString, strings.Repeat(".", maxPatternSide) + "O\n"},
+	}
+	for _, tt := range tests {
+		if _, err := tt.read(tt.input); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
+
+func TestReadersKeepSmallPatterns(t *testing.T) {
+	p, err := readLife106String("#Life 1.06\n-1 0\n0 1\n1 -1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.width != 3 || p.height != 3 || p.at(0, 1) != 1 || p.at(1, 2) != 1 || p.at(2, 0) != 1 {
+		t.Errorf("life 1.06: got %dx%d %v", p.width, p.height, p.cells)
+	}
+
+	p, err = readPlaintextString("!Name: Blinker\nOOO\n...\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.name != "Blinker" || p.width != 3 || p.height != 2 || p.at(2, 0) != 1 {
+		t.Errorf("plaintext: got %q %dx%d %v", p.name, p.width, p.height, p.cells)
+	}
+}
+
+// TestSavePatternKeepsFileOnError saves a pattern with dying cells in a
+// format that cannot hold them over a file that is already there.
+func TestSavePatternKeepsFileOnError(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"old.cells", "old.lif"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("keep me\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		p := newPattern(2, 1)
+		p.set(0, 0, 1)
+		p.set(1, 0, 2)
+		if err := savePattern(path, p); err == nil {
+			t.Errorf("%s: no error saving dying cells", name)
+		}
+		if data, err := os.ReadFile(path); err != nil || string(data) != "keep me\n" {
+			t.Errorf("%s: file now holds %q, %v", name, data, err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("%d files left in the directory, want the 2 saved over", len(entries))
+	}
+
+	path := filepath.Join(dir, "old.cells")
+	p := newPattern(1, 1)
+	p.set(0, 0, 1)
+	if err := savePattern(path, p); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "O") {
+		t.Errorf("saved file holds %q", data)
+	}
+}
+
+func readLife106String(s string) (*Pattern, error) {
+	return readLife106(strings.NewReader(s))
+}
+
+func readPlaintextString(s string) (*Pattern, error) {
+	return readPlaintext(strings.NewReader(s))
+}
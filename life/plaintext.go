@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The plaintext format, usually saved as .cells, draws the pattern with one
+// line per row, . for dead and O for live cells. Lines starting with ! are
+// comments and "!Name: " names the pattern:
+//
+//	!Name: Glider
+//	.O
+//	..O
+//	OOO
+
+var errTooManyStates = errors.New("format only holds dead and live cells")
+
+func readPlaintext(r io.Reader) (*Pattern, error) {
+	p := &Pattern{}
+	var cells []patternCell
+
+	y := 0
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+
+		if strings.HasPrefix(line, "!") {
+			body := strings.TrimSpace(line[1:])
+			if name, ok := strings.CutPrefix(body, "Name:"); ok {
+				p.name = strings.TrimSpace(name)
+			} else {
+				p.comments = append(p.comments, body)
+			}
+			continue
+		}
+
+		for x, ch := range line {
+			switch ch {
+			case '.':
+			case 'O', 'o', '*':
+				cells = append(cells, patternCell{x: x, y: y, state: 1})
+			default:
+				return nil, fmt.Errorf("plaintext: unexpected %q", ch)
+			}
+		}
+		y++
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	// Keep empty rows at the bottom, they are part of the pattern.
+	p.height = y
+	if err := p.fromCells(cells); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func writePlaintext(w io.Writer, p *Pattern) error {
+	bw := bufio.NewWriter(w)
+
+	if p.name != "" {
+		fmt.Fprintf(bw, "!Name: %s\n", p.name)
+	}
+	for _, c := range p.comments {
+		fmt.Fprintf(bw, "!%s\n", c)
+	}
+
+	row := make([]byte, p.width)
+	for y := 0; y < p.height; y++ {
+		for x := range row {
+			switch p.at(x, y) {
+			case 0:
+				row[x] = '.'
+			case 1:
+				row[x] = 'O'
+			default:
+				return errTooManyStates
+			}
+		}
+		bw.Write(row)
+		bw.WriteByte('\n')
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/bits"
+
+	"epractice/life/sim"
+)
+
+// renderMode is what the colour of a cell shows.
+type renderMode int
+
+const (
+	// renderStates draws live cells white and dying ones fading to red.
+	renderStates renderMode = iota
+	// renderAge colours live cells by how many generations they have lived.
+	renderAge
+	// renderTrail leaves a glow where cells lived that fades over a few
+	// generations.
+	renderTrail
+	// renderActivity colours every cell by how often it has come alive or
+	// died.
+	renderActivity
+)
+
+var renderModeLabels = [...]string{
+	renderStates:   "Cell states",
+	renderAge:      "Age, green newborn to blue old",
+	renderTrail:    "Trail of recently dead cells",
+	renderActivity: "Activity, how often cells toggled",
+}
+
+func (m renderMode) String() string {
+	return renderModeLabels[m]
+}
+
+func (m renderMode) next() renderMode {
+	return (m + 1) % renderMode(len(renderModeLabels))
+}
+
+const (
+	// maxHeat is the trail value of a live cell. It drops by trailDecay a
+	// generation once the cell is dead.
+	maxHeat    = 255
+	trailDecay = 24
+	// oldAge is the age at which cells are drawn fully blue.
+	oldAge = 256
+)
+
+// valueTileSize is the width and height of a valueTile. A row of live is
+// one word.
+const valueTileSize = 64
+
+// valueKey is the position of a valueTile: the tile at x, y covers the cells
+// from x*64, y*64 up to but not including (x+1)*64, (y+1)*64.
+type valueKey struct {
+	x, y int
+}
+
+// valueTileAt returns the key of the tile holding the cell at x, y and the
+// position of the cell within it.
+func valueTileAt(x, y int) (k valueKey, tx, ty int) {
+	return valueKey{x >> 6, y >> 6}, x & (valueTileSize - 1), y & (valueTileSize - 1)
+}
+
+// valueTile holds a value for each cell of a 64x64 tile, indexed by
+// y*valueTileSize+x, and which of its cells were alive at the last update,
+// bit x of live[y] for the cell at x, y within the tile.
+type valueTile struct {
+	live  [valueTileSize]uint64
+	value [valueTileSize * valueTileSize]uint32
+}
+
+func (t *valueTile) empty() bool {
+	for y := range t.live {
+		if t.live[y] != 0 {
+			return false
+		}
+	}
+	for _, v := range t.value {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// cellValues keeps a value for every cell of the board for the render modes
+// that need more than the current state, updated once a generation from
+// when the mode is picked. Only tiles with live cells or values are stored.
+type cellValues struct {
+	tiles map[valueKey]*valueTile
+	// highest is the largest value, to scale the activity colours by.
+	highest uint32
+	// version is the Grid.version of the board the values are for.
+	version int
+}
+
+func (c *cellValues) reset() {
+	c.tiles = make(map[valueKey]*valueTile)
+	c.highest = 0
+}
+
+// mark records which cells are alive now and returns which were alive
+// before, by tile.
+func (c *cellValues) mark(cells sim.Universe) map[valueKey][valueTileSize]uint64 {
+	if c.tiles == nil {
+		c.reset()
+	}
+
+	was := make(map[valueKey][valueTileSize]uint64, len(c.tiles))
+	for k, t := range c.tiles {
+		was[k] = t.live
+		t.live = [valueTileSize]uint64{}
+	}
+	if minX, minY, maxX, maxY, ok := cells.Bounds(); ok {
+		cells.ForEachIn(minX, minY, maxX+1, maxY+1, func(x, y int, state uint8) {
+			if state != 1 {
+				return
+			}
+			k, tx, ty := valueTileAt(x, y)
+			t := c.tiles[k]
+			if t == nil {
+				t = &valueTile{}
+				c.tiles[k] = t
+			}
+			t.live[ty] |= 1 << uint(tx)
+		})
+	}
+	return was
+}
+
+// update works out the values after a generation under the given mode.
+func (c *cellValues) update(cells sim.Universe, mode renderMode) {
+	wasLive := c.mark(cells)
+
+	c.highest = 0
+	for k, t := range c.tiles {
+		was := wasLive[k]
+		for y := 0; y < valueTileSize; y++ {
+			row := t.value[y*valueTileSize : (y+1)*valueTileSize]
+			switch mode {
+			case renderAge:
+				for x := range row {
+					if t.live[y]&(1<<uint(x)) != 0 {
+						row[x] = min(row[x]+1, math.MaxUint32-1)
+					} else {
+						row[x] = 0
+					}
+				}
+			case renderTrail:
+				for x := range row {
+					if t.live[y]&(1<<uint(x)) != 0 {
+						row[x] = maxHeat
+					} else {
+						row[x] -= min(row[x], trailDecay)
+					}
+				}
+			case renderActivity:
+				for w := t.live[y] ^ was[y]; w != 0; w &= w - 1 {
+					row[bits.TrailingZeros64(w)]++
+				}
+			}
+			for _, v := range row {
+				c.highest = max(c.highest, v)
+			}
+		}
+		if t.empty() {
+			delete(c.tiles, k)
+		}
+	}
+}
+
+// forEachIn calls fn for every cell with x0 <= x < x1, y0 <= y < y1 that has
+// a value.
+func (c *cellValues) forEachIn(x0, y0, x1, y1 int, fn func(x, y int, v uint32)) {
+	for k, t := range c.tiles {
+		for ty := 0; ty < valueTileSize; ty++ {
+			y := k.y*valueTileSize + ty
+			if y < y0 || y >= y1 {
+				continue
+			}
+			for tx, v := range t.value[ty*valueTileSize : (ty+1)*valueTileSize] {
+				if x := k.x*valueTileSize + tx; v != 0 && x >= x0 && x < x1 {
+					fn(x, y, v)
+				}
+			}
+		}
+	}
+}
+
+func (c *cellValues) at(x, y int) uint32 {
+	k, tx, ty := valueTileAt(x, y)
+	if t := c.tiles[k]; t != nil {
+		return t.value[ty*valueTileSize+tx]
+	}
+	return 0
+}
+
+// setRenderMode switches to mode, starting its values afresh from the
+// current board.
+func (g *Grid) setRenderMode(mode renderMode) {
+	g.renderMode = mode
+	g.values.reset()
+	if mode != renderStates {
+		g.values.mark(g.cells)
+	}
+	g.values.version = g.version
+}
+
+// syncValues starts the values afresh when the board was changed other than
+// by a step since they were worked out: edited, painted, cleared, loaded or
+// taken back in history. What the values say about the old board does not
+// hold for the new one.
+func (g *Grid) syncValues() {
+	if g.values.version != g.version {
+		g.setRenderMode(g.renderMode)
+	}
+}
+
+// cellColor returns the colour of a cell that is not dead.
+func (g *Grid) cellColor(x, y int, state uint8) color.RGBA {
+	if g.renderMode != renderAge || state != 1 {
+		return g.stateColor(state)
+	}
+	// Cells that have not been through a step yet are newborn.
+	t := math.Log2(float64(max(g.values.at(x, y), 1))) / math.Log2(oldAge)
+	return lerpColor(color.RGBA{90, 255, 120, 255}, color.RGBA{70, 90, 255, 255}, t)
+}
+
+// valueColor returns the colour of a cell with the value v in the trail and
+// activity modes.
+func (g *Grid) valueColor(v uint32) color.RGBA {
+	if g.renderMode == renderTrail {
+		return lerpColor(color.RGBA{40, 0, 0, 255}, color.RGBA{255, 120, 20, 255}, float64(v)/maxHeat)
+	}
+	t := math.Log2(float64(v)+1) / math.Log2(float64(g.values.highest)+1)
+	return lerpColor(color.RGBA{20, 20, 90, 255}, color.RGBA{255, 230, 60, 255}, t)
+}
+
+// lerpColor mixes a and b, all a at t = 0 and all b at t = 1.
+func lerpColor(a, b color.RGBA, t float64) color.RGBA {
+	t = math.Max(0, math.Min(1, t))
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*t)
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"epractice/life/sim"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// cellShaderSrc scales up a texture with one texel per cell and draws the
+// grid lines between cells. Board is the part of the texture, in cells, that
+// lies on the board; lines are only drawn there.
+const cellShaderSrc = `//kage:unit pixels
+
+package main
+
+var Zoom float
+var Edge float
+var GridColor vec4
+var Board vec4
+
+func Fragment(dst vec4, src vec2, color vec4) vec4 {
+	cell := floor(src)
+	c := cell - imageSrc0Origin()
+	f := (src - cell) * Zoom
+
+	if f.x < Edge && c.x >= Board.x && c.x <= Board.z && c.y >= Board.y && c.y < Board.w {
+		return GridColor
+	}
+	if f.y < Edge && c.y >= Board.y && c.y <= Board.w && c.x >= Board.x && c.x < Board.z {
+		return GridColor
+	}
+	return imageSrc0At(cell + 0.5)
+}
+`
+
+// cellRenderer draws the cells in the view. The colours of the cells in the
+// view are written into a texture with one texel per cell, only when the
+// cells or the part of the world in the view have changed, and the texture
+// is scaled up to the view by cellShaderSrc. Nothing is allocated on frames
+// where nothing changed.
+type cellRenderer struct {
+	shader  *ebiten.Shader
+	texture *ebiten.Image
+	pixels  []byte
+
+	vertices [4]ebiten.Vertex
+	options  ebiten.DrawTrianglesShaderOptions
+	// The uniforms of the shader, kept as slices so that setting them
+	// does not allocate.
+	zoom      []float32
+	edge      []float32
+	gridColor []float32
+	board     []float32
+
+	// What the texture holds: the cells from originX, originY on at version
+	// of the grid, ok once it has been filled.
+	ok               bool
+	version          int
+	originX, originY int
+	cols, rows       int
+	mode             renderMode
+}
+
+var cellIndices = []uint16{0, 1, 2, 1, 2, 3}
+
+func (r *cellRenderer) init() {
+	shader, err := ebiten.NewShader([]byte(cellShaderSrc))
+	if err != nil {
+		panic(err)
+	}
+	r.shader = shader
+
+	r.zoom = make([]float32, 1)
+	r.edge = make([]float32, 1)
+	r.gridColor = make([]float32, 4)
+	r.board = make([]float32, 4)
+	r.options.Uniforms = map[string]any{
+		"Zoom":      r.zoom,
+		"Edge":      r.edge,
+		"GridColor": r.gridColor,
+		"Board":     r.board,
+	}
+	for i := range r.vertices {
+		r.vertices[i].ColorR, r.vertices[i].ColorG, r.vertices[i].ColorB, r.vertices[i].ColorA = 1, 1, 1, 1
+	}
+}
+
+// resize makes the texture big enough for a view of the given size, which
+// only allocates when the view has been resized.
+func (r *cellRenderer) resize(viewWidth, viewHeight int) {
+	// At the smallest zoom of one pixel per cell the view shows parts of
+	// one more cell than it has pixels across.
+	w, h := viewWidth+2, viewHeight+2
+	if r.texture != nil {
+		if b := r.texture.Bounds(); b.Dx() == w && b.Dy() == h {
+			return
+		}
+		r.texture.Dispose()
+	}
+	r.texture = ebiten.NewImageWithOptions(image.Rect(0, 0, w, h), &ebiten.NewImageOptions{Unmanaged: true})
+	r.pixels = make([]byte, 4*w*h)
+	r.options.Images[0] = r.texture
+	r.ok = false
+}
+
+// changed notes that the cells changed and need to be drawn again.
+func (g *Grid) changed() {
+	g.version++
+}
+
+// drawCells draws the cells in the view, with grid lines once cells are big
+// enough to tell apart. The view is covered exactly, so nothing needs
+// clipping.
+func (g *Grid) drawCells(screen *ebiten.Image) {
+	r := &g.renderer
+	if r.shader == nil {
+		r.init()
+	}
+	r.resize(g.viewWidth, g.viewHeight)
+	g.syncValues()
+
+	zoom := g.camera.zoom
+	originX, originY := int(math.Floor(g.camera.x)), int(math.Floor(g.camera.y))
+	cols := int(math.Ceil(g.camera.x+float64(g.viewWidth)/zoom)) - originX
+	rows := int(math.Ceil(g.camera.y+float64(g.viewHeight)/zoom)) - originY
+	if !r.ok || r.version != g.version || r.mode != g.renderMode ||
+		r.originX != originX || r.originY != originY || r.cols != cols || r.rows != rows {
+		r.ok, r.version, r.mode = true, g.version, g.renderMode
+		r.originX, r.originY, r.cols, r.rows = originX, originY, cols, rows
+		g.fillTexture()
+	}
+
+	r.zoom[0] = float32(zoom)
+	r.edge[0] = 0
+	if zoom >= 4 {
+		r.edge[0] = float32(g.edgeWidth)
+	}
+	cr, cg, cb, ca := gridLineColor.RGBA()
+	r.gridColor[0], r.gridColor[1], r.gridColor[2], r.gridColor[3] = float32(cr)/0xffff, float32(cg)/0xffff, float32(cb)/0xffff, float32(ca)/0xffff
+	if g.topology == sim.Unbounded {
+		r.board[0], r.board[1], r.board[2], r.board[3] = -1, -1, float32(cols+1), float32(rows+1)
+	} else {
+		r.board[0], r.board[1] = float32(-originX), float32(-originY)
+		r.board[2], r.board[3] = float32(g.cols-originX), float32(g.rows-originY)
+	}
+
+	// The corners of the view and the points of the texture they show.
+	left, top := float32(g.camera.x)-float32(originX), float32(g.camera.y)-float32(originY)
+	right, bottom := left+float32(float64(g.viewWidth)/zoom), top+float32(float64(g.viewHeight)/zoom)
+	x0, y0 := float32(g.startX), float32(g.startY)
+	x1, y1 := x0+float32(g.viewWidth), y0+float32(g.viewHeight)
+	for i, v := range [4][4]float32{
+		{x0, y0, left, top},
+		{x1, y0, right, top},
+		{x0, y1, left, bottom},
+		{x1, y1, right, bottom},
+	} {
+		r.vertices[i].DstX, r.vertices[i].DstY = v[0], v[1]
+		r.vertices[i].SrcX, r.vertices[i].SrcY = v[2], v[3]
+	}
+	screen.DrawTrianglesShader(r.vertices[:], cellIndices, r.shader, &r.options)
+}
+
+// fillTexture writes the colours of the cells the renderer shows into its
+// texture.
+func (g *Grid) fillTexture() {
+	r := &g.renderer
+	stride := r.texture.Bounds().Dx()
+	set := func(x, y int, c color.RGBA) {
+		i := 4 * ((y-r.originY)*stride + x - r.originX)
+		r.pixels[i], r.pixels[i+1], r.pixels[i+2], r.pixels[i+3] = c.R, c.G, c.B, c.A
+	}
+
+	x0, y0 := r.originX, r.originY
+	x1, y1 := x0+r.cols, y0+r.rows
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			if g.topology == sim.Unbounded || x >= 0 && x < g.cols && y >= 0 && y < g.rows {
+				set(x, y, deadColor)
+			} else {
+				set(x, y, outsideBoardColor)
+			}
+		}
+	}
+
+	if g.renderMode == renderTrail || g.renderMode == renderActivity {
+		g.values.forEachIn(x0, y0, x1, y1, func(x, y int, v uint32) {
+			set(x, y, g.valueColor(v))
+		})
+	}
+	g.cells.ForEachIn(x0, y0, x1, y1, func(x, y int, state uint8) {
+		set(x, y, g.cellColor(x, y, state))
+	})
+	r.texture.WritePixels(r.pixels)
+}
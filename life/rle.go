@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// RLE is the run length encoded pattern format used by LifeWiki and Golly:
+//
+//	#N Glider
+//	#C The smallest spaceship.
+//	x = 3, y = 3, rule = B3/S23
+//	bob$2bo$3o!
+//
+// Runs of cells are written as an optional count and a tag: b for dead and o
+// for alive cells, $ for the end of a row and ! for the end of the pattern.
+// Patterns with more than two states use . for dead and A to X for states 1
+// to 24, with p to y in front for higher states.
+
+// rleLineLength is the longest line writeRLE produces.
+const rleLineLength = 70
+
+func readRLE(r io.Reader) (*Pattern, error) {
+	p := &Pattern{}
+	var cells []patternCell
+
+	x, y := 0, 0
+	count := 0
+	prefix := 0
+	headerSeen := false
+	done := false
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for !done && sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#"):
+			readRLEComment(p, line)
+			continue
+		case !headerSeen && strings.HasPrefix(line, "x"):
+			if err := readRLEHeader(p, line); err != nil {
+				return nil, err
+			}
+			headerSeen = true
+			continue
+		}
+
+		for _, ch := range line {
+			run := max(count, 1)
+
+			switch {
+			case ch >= '0' && ch <= '9':
+				count = count*10 + int(ch-'0')
+				if count > maxPatternSide {
+					return nil, fmt.Errorf("rle: run of more than %d cells", maxPatternSide)
+				}
+				continue
+			case ch >= 'p' && ch <= 'y':
+				prefix = int(ch-'p') + 1
+				continue
+			case ch == 'b' || ch == '.':
+				x += run
+			case ch == 'o' || ch >= 'A' && ch <= 'X':
+				state := 1
+				if ch != 'o' {
+					state = prefix*24 + int(ch-'A') + 1
+				}
+				if state > 255 {
+					return nil, fmt.Errorf("rle: state %d out of range", state)
+				}
+				for i := 0; i < run; i++ {
+					cells = append(cells, patternCell{x: x, y: y, state: uint8(state)})
+					x++
+				}
+			case ch == '$':
+				x = 0
+				y += run
+			case ch == '!':
+				done = true
+			case ch == ' ' || ch == '\t':
+			default:
+				return nil, fmt.Errorf("rle: unexpected %q", ch)
+			}
+			if done {
+				break
+			}
+			if x > maxPatternSide || y > maxPatternSide {
+				return nil, fmt.Errorf("rle: pattern larger than %d cells across", maxPatternSide)
+			}
+			count = 0
+			prefix = 0
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := p.fromCells(cells); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func readRLEComment(p *Pattern, line string) {
+	if len(line) < 2 {
+		return
+	}
+	body := strings.TrimSpace(line[2:])
+	switch line[1] {
+	case 'N':
+		p.name = body
+	case 'C', 'c', 'O':
+		p.comments = append(p.comments, body)
+	case 'r':
+		// Old files put the rule in a comment, in S/B order.
+		p.rule = body
+	}
+}
+
+// readRLEHeader reads a header line like "x = 3, y = 3, rule = B3/S23".
+func readRLEHeader(p *Pattern, line string) error {
+	for _, field := range strings.Split(line, ",") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return fmt.Errorf("rle: bad header %q", line)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		var err error
+		switch key {
+		case "x":
+			p.width, err = readRLESize(value)
+		case "y":
+			p.height, err = readRLESize(value)
+		case "rule":
+			p.rule = value
+		}
+		if err != nil {
+			return fmt.Errorf("rle: bad header %q: %w", line, err)
+		}
+	}
+	return nil
+}
+
+// readRLESize reads the width or height given in a header.
+func readRLESize(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	switch {
+	case err != nil:
+		return 0, err
+	case n < 0 || n > maxPatternSide:
+		return 0, fmt.Errorf("size %d out of range", n)
+	}
+	return n, nil
+}
+
+func writeRLE(w io.Writer, p *Pattern) error {
+	bw := bufio.NewWriter(w)
+
+	if p.name != "" {
+		fmt.Fprintf(bw, "#N %s\n", p.name)
+	}
+	for _, c := range p.comments {
+		fmt.Fprintf(bw, "#C %s\n", c)
+	}
+	fmt.Fprintf(bw, "x = %d, y = %d", p.width, p.height)
+	if p.rule != "" {
+		fmt.Fprintf(bw, ", rule = %s", p.rule)
+	}
+	bw.WriteByte('\n')
+
+	multiState := false
+	for _, s := range p.cells {
+		if s > 1 {
+			multiState = true
+			break
+		}
+	}
+
+	lw := lineWriter{w: bw, limit: rleLineLength}
+	rowEnds := 0
+	for y := 0; y < p.height; y++ {
+		for x := 0; x < p.width; {
+			state := p.at(x, y)
+			run := 1
+			for x+run < p.width && p.at(x+run, y) == state {
+				run++
+			}
+			x += run
+			if state == 0 && x == p.width {
+				// Dead cells at the end of a row are left out.
+				break
+			}
+
+			if rowEnds > 0 {
+				lw.write(rleRun(rowEnds, "$"))
+				rowEnds = 0
+			}
+			lw.write(rleRun(run, rleTag(state, multiState)))
+		}
+		rowEnds++
+	}
+	lw.write("!")
+	bw.WriteByte('\n')
+
+	return bw.Flush()
+}
+
+func rleRun(n int, tag string) string {
+	if n == 1 {
+		return tag
+	}
+	return strconv.Itoa(n) + tag
+}
+
+func rleTag(state uint8, multiState bool) string {
+	switch {
+	case !multiState && state == 0:
+		return "b"
+	case !multiState:
+		return "o"
+	case state == 0:
+		return "."
+	case state <= 24:
+		return string(rune('A' + state - 1))
+	}
+	s := int(state) - 25
+	return string([]byte{byte('p' + s/24), byte('A' + s%24)})
+}
+
+// lineWriter writes tokens on lines no longer than limit, starting a new
+// line rather than splitting a token.
+type lineWriter struct {
+	w     *bufio.Writer
+	limit int
+	n     int
+}
+
+func (lw *lineWriter) write(token string) {
+	if lw.n > 0 && lw.n+len(token) > lw.limit {
+		lw.w.WriteByte('\n')
+		lw.n = 0
+	}
+	lw.w.WriteString(token)
+	lw.n += len(token)
+}
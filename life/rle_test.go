@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestRLEReadsGlider(t *testing.T) {
+	p, err := readRLE(strings.NewReader("#N Glider\nx = 3, y = 3, rule = B3/S23\nbob$2bo$3o!\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.name != "Glider" || p.rule != "B3/S23" || p.width != 3 || p.height != 3 {
+		t.Fatalf("got %q, rule %q, %dx%d", p.name, p.rule, p.width, p.height)
+	}
+	want := []uint8{
+		0, 1, 0,
+		0, 0, 1,
+		1, 1, 1,
+	}
+	if !bytes.Equal(p.cells, want) {
+		t.Errorf("cells %v, want %v", p.cells, want)
+	}
+}
+
+// TestRLERoundTripsStamps writes every stamp and reads it back.
+func TestRLERoundTripsStamps(t *testing.T) {
+	paths, err := fs.Glob(stampFiles, "stamps/*.rle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			data, err := stampFiles.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := readRLE(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := writeRLE(&buf, p); err != nil {
+				t.Fatal(err)
+			}
+			checkLineLength(t, buf.String())
+
+			q, err := readRLE(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q.name != p.name || q.rule != p.rule || q.width != p.width || q.height != p.height || !bytes.Equal(q.cells, p.cells) {
+				t.Errorf("read back %q %dx%d, want %q %dx%d with the same cells", q.name, q.width, q.height, p.name, p.width, p.height)
+			}
+		})
+	}
+}
+
+// TestRLEWrapsLines writes rows of single cells, every one its own token,
+// and checks that they are wrapped at rleLineLength without splitting runs.
+func TestRLEWrapsLines(t *testing.T) {
+	p := newPattern(300, 3)
+	for y := 0; y < p.height; y++ {
+		for x := y; x < p.width; x += 2 + x%5 {
+			p.set(x, y, 1)
+		}
+	}
+	var buf bytes.Buffer
+	if err := writeRLE(&buf, p); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	lines := checkLineLength(t, out)
+	if len(lines) < 3 {
+		t.Errorf("got %d lines, want the cells wrapped over several", len(lines))
+	}
+
+	q, err := readRLE(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(q.cells, p.cells) {
+		t.Error("wrapped pattern reads back different")
+	}
+}
+
+// checkLineLength fails t for lines of out longer than rleLineLength and
+// returns the lines.
+func checkLineLength(t *testing.T, out string) []string {
+	t.Helper()
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	for i, line := range lines {
+		if len(line) > rleLineLength {
+			t.Errorf("line %d is %d long, want at most %d: %q", i+1, len(line), rleLineLength, line)
+		}
+	}
+	return lines
+}
+
+func TestRLERejectsBadSizes(t *testing.T) {
+	for _, s := range []string{
+		"x = -3, y = 3\n!",
+		"x = 3, y = -1\nbo!",
+		"x = 100000000, y = 3\n!",
+		"x = 3, y = 3\n99999999999o!",
+		"x = 3, y = 3\n65536o65536o!",
+		"x = 3, y = 3\n60000$60000$o!",
+		"x = 60000, y = 60000\n!",
+	} {
+		if _, err := readRLE(strings.NewReader(s)); err == nil {
+			t.Errorf("%q: no error", s)
+		}
+	}
+}
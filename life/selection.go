@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// selection is a rectangle of cells picked with a shift-drag, from the cell
+// the drag started on to the one it is over now, both included.
+type selection struct {
+	active bool
+	ax, ay int
+	bx, by int
+}
+
+// rect returns the selected cells as x0 <= x < x1, y0 <= y < y1.
+func (s selection) rect() (x0, y0, x1, y1 int) {
+	return min(s.ax, s.bx), min(s.ay, s.by), max(s.ax, s.bx) + 1, max(s.ay, s.by) + 1
+}
+
+var (
+	selectionColor     = color.RGBA{255, 200, 60, 255}
+	selectionFillColor = color.RGBA{40, 30, 10, 40}
+)
+
+// handleSelection selects the cells a shift-drag with the left button goes
+// over. It reports whether it used the mouse, in which case the click does
+// nothing else.
+func (g *Grid) handleSelection(mx, my int) bool {
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) && ebiten.IsKeyPressed(ebiten.KeyShift) && g.inView(mx, my) {
+		x, y := g.camera.toWorld(mx-g.startX, my-g.startY)
+		g.selection = selection{active: true, ax: x, ay: y, bx: x, by: y}
+		g.selecting = true
+		return true
+	}
+	if !g.selecting {
+		return false
+	}
+
+	if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		g.selecting = false
+		x0, y0, x1, y1 := g.selection.rect()
+		g.notice = fmt.Sprintf("Selected %dx%d, Ctrl+C copy, Ctrl+X cut, Delete clear, I invert, D random fill", x1-x0, y1-y0)
+		return true
+	}
+	g.selection.bx, g.selection.by = g.camera.toWorld(mx-g.startX, my-g.startY)
+	return true
+}
+
+// region returns the cells with x0 <= x < x1, y0 <= y < y1 as a pattern.
+func (g *Grid) region(x0, y0, x1, y1 int) *Pattern {
+	p := newPattern(x1-x0, y1-y0)
+	p.rule = g.rule.String()
+	g.cells.ForEachIn(x0, y0, x1, y1, func(x, y int, state uint8) {
+		p.set(x-x0, y-y0, state)
+	})
+	return p
+}
+
+// fillRegion sets every cell of the selection to what state returns for it.
+func (g *Grid) fillRegion(state func(x, y int) uint8) {
+	x0, y0, x1, y1 := g.selection.rect()
+	g.edit()
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			g.cells.SetCell(x, y, state(x, y))
+		}
+	}
+}
+
+// copySelection keeps the selected cells to paste later, and puts them on the
+// system clipboard as RLE when there is one.
+func (g *Grid) copySelection() {
+	if !g.selection.active {
+		return
+	}
+	g.clipboard = g.region(g.selection.rect())
+
+	var buf bytes.Buffer
+	if err := writeRLE(&buf, g.clipboard); err != nil {
+		g.notice = "Copy failed: " + err.Error()
+		return
+	}
+	if err := writeClipboard(buf.String()); err != nil {
+		g.notice = fmt.Sprintf("Copied %dx%d, not to the system clipboard: %v", g.clipboard.width, g.clipboard.height, err)
+		return
+	}
+	g.notice = fmt.Sprintf("Copied %dx%d", g.clipboard.width, g.clipboard.height)
+}
+
+func (g *Grid) cutSelection() {
+	if !g.selection.active {
+		return
+	}
+	g.copySelection()
+	g.clearSelection()
+}
+
+func (g *Grid) clearSelection() {
+	if !g.selection.active {
+		return
+	}
+	g.fillRegion(func(x, y int) uint8 { return 0 })
+}
+
+// invertSelection brings the dead and dying cells of the selection to life
+// and kills the live ones.
+func (g *Grid) invertSelection() {
+	if !g.selection.active {
+		return
+	}
+	g.fillRegion(func(x, y int) uint8 {
+		if g.cells.Cell(x, y) == 1 {
+			return 0
+		}
+		return 1
+	})
+}
+
+// randomFillSelection brings each cell of the selection to life with
+// probability fillDensity, and kills it otherwise.
+func (g *Grid) randomFillSelection() {
+	if !g.selection.active {
+		return
+	}
+	g.fillRegion(func(x, y int) uint8 {
+		if rand.Float64() < g.fillDensity {
+			return 1
+		}
+		return 0
+	})
+}
+
+// paste picks up the pattern on the system clipboard, or the last one copied
+// when the clipboard holds no pattern, as the stamp to place with a click.
+func (g *Grid) paste() {
+	p := g.clipboard
+	if text, err := readClipboard(); err == nil && strings.TrimSpace(text) != "" {
+		data := []byte(text)
+		if cp, err := sniffFormat(data).read(bytes.NewReader(data)); err == nil && cp.width > 0 {
+			p = cp
+		}
+	}
+	if p == nil {
+		g.notice = "Nothing to paste"
+		return
+	}
+	if p.name == "" {
+		p.name = fmt.Sprintf("Pasted %dx%d", p.width, p.height)
+	}
+	g.pickStamp(p)
+}
+
+// drawSelection outlines the selection.
+func (g *Grid) drawSelection(screen *ebiten.Image) {
+	if !g.selection.active {
+		return
+	}
+
+	view := screen.SubImage(image.Rect(g.startX, g.startY, g.startX+g.viewWidth, g.startY+g.viewHeight)).(*ebiten.Image)
+	x0, y0, x1, y1 := g.selection.rect()
+	left, top := g.camera.toView(x0, y0)
+	right, bottom := g.camera.toView(x1, y1)
+	sx, sy := float32(g.startX)+float32(left), float32(g.startY)+float32(top)
+	w, h := float32(right-left), float32(bottom-top)
+	vector.DrawFilledRect(view, sx, sy, w, h, selectionFillColor, false)
+	vector.StrokeRect(view, sx, sy, w, h, 2, selectionColor, false)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"epractice/life/sim"
+)
+
+// sessionVersion is the version of the session file format. Files with a
+// later version are refused rather than half read.
+const sessionVersion = 1
+
+// session is everything needed to pick up where a run left off, as saved in
+// a session file.
+type session struct {
+	Version int `json:"version"`
+
+	Rule     string `json:"rule"`
+	Topology string `json:"topology"`
+	Rows     int    `json:"rows"`
+	Cols     int    `json:"cols"`
+	HashLife bool   `json:"hashLife"`
+	Jump     int    `json:"jump"`
+
+	Generation int `json:"generation"`
+	// Speed is in steps per second, 0 for as fast as possible.
+	Speed float64 `json:"speed"`
+
+	Camera struct {
+		X    float64 `json:"x"`
+		Y    float64 `json:"y"`
+		Zoom float64 `json:"zoom"`
+	} `json:"camera"`
+
+	// Cells holds the cells that are not dead as RLE, with its top left
+	// corner at CellsX, CellsY.
+	CellsX int    `json:"cellsX"`
+	CellsY int    `json:"cellsY"`
+	Cells  string `json:"cells"`
+}
+
+// session returns the state of the grid as a session.
+func (g *Grid) session() (*session, error) {
+	s := &session{
+		Version:    sessionVersion,
+		Rule:       g.rule.String(),
+		Topology:   g.topology.String(),
+		Rows:       g.rows,
+		Cols:       g.cols,
+		HashLife:   g.useHashLife,
+		Jump:       g.jump,
+		Generation: g.generation,
+		Speed:      speeds[g.speed],
+	}
+	s.Camera.X, s.Camera.Y, s.Camera.Zoom = g.camera.x, g.camera.y, g.camera.zoom
+
+	if minX, minY, _, _, ok := g.cells.Bounds(); ok {
+		s.CellsX, s.CellsY = minX, minY
+	}
+	var buf bytes.Buffer
+	if err := writeRLE(&buf, g.pattern()); err != nil {
+		return nil, err
+	}
+	s.Cells = buf.String()
+	return s, nil
+}
+
+// restoreSession puts the grid in the state saved in s. The grid is left as
+// it was when s cannot be read.
+func (g *Grid) restoreSession(s *session) error {
+	if s.Version > sessionVersion {
+		return fmt.Errorf("session version %d is newer than this program reads (%d)", s.Version, sessionVersion)
+	}
+	rule, err := sim.ParseRule(s.Rule)
+	if err != nil {
+		return err
+	}
+	topology, err := sim.ParseTopology(s.Topology)
+	if err != nil {
+		return err
+	}
+	p, err := readRLE(strings.NewReader(s.Cells))
+	if err != nil {
+		return err
+	}
+	if topology != sim.Unbounded && (s.Rows <= 0 || s.Cols <= 0) {
+		return fmt.Errorf("session board size %dx%d", s.Cols, s.Rows)
+	}
+
+	g.run = false
+	g.rule = rule
+	g.topology = topology
+	if s.Rows > 0 && s.Cols > 0 {
+		g.rows, g.cols = s.Rows, s.Cols
+	}
+	g.useHashLife = s.HashLife && topology == sim.Unbounded && sim.HashLifeSupports(rule)
+	g.jump = max(0, min(s.Jump, maxJump))
+	g.cells = nil
+	g.rebuild()
+	for y := 0; y < p.height; y++ {
+		for x := 0; x < p.width; x++ {
+			if state := p.at(x, y); state != 0 {
+				g.cells.SetCell(s.CellsX+x, s.CellsY+y, state)
+			}
+		}
+	}
+
+	g.generation = s.Generation
+	g.speed = speedIndex(s.Speed)
+	if s.Camera.Zoom >= minZoom && s.Camera.Zoom <= maxZoom {
+		g.camera = camera{x: s.Camera.X, y: s.Camera.Y, zoom: s.Camera.Zoom}
+	}
+	g.stats = stats{}
+	g.setRenderMode(g.renderMode)
+	return nil
+}
+
+// speedIndex returns the index in speeds of the slowest speed at least
+// perSecond fast.
+func speedIndex(perSecond float64) int {
+	for i, s := range speeds {
+		if s == 0 || s >= perSecond && perSecond > 0 {
+			return i
+		}
+	}
+	return len(speeds) - 1
+}
+
+func (g *Grid) saveSession(path string) error {
+	s, err := g.session()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func (g *Grid) loadSession(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var s session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := g.restoreSession(&s); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// autosavePath returns where the session is saved on exit, in the user's
+// configuration directory.
+func autosavePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "life", "autosave.json"), nil
+}
+
+// autosave saves the session for the next launch to restore.
+func (g *Grid) autosave() error {
+	path, err := autosavePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return g.saveSession(path)
+}
+
+// restoreAutosave restores the session saved on the last exit. It reports
+// false without an error when there is none.
+func (g *Grid) restoreAutosave() (bool, error) {
+	path, err := autosavePath()
+	if err != nil {
+		return false, err
+	}
+	if err := g.loadSession(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
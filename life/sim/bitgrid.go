@@ -0,0 +1,231 @@
+package sim
+
+import "math/bits"
+
+// bitGrid is a packed bitmap of cells stored row-major. Every row takes
+// stride uint64 words and bit i of word w in row y is the cell at x = w*64+i.
+// Bits past cols in the last word of a row are always kept zero.
+type bitGrid struct {
+	rows   int
+	cols   int
+	stride int
+
+	words []uint64
+}
+
+func newBitGrid(rows, cols int) *bitGrid {
+	stride := (cols + 63) / 64
+	return &bitGrid{
+		rows:   rows,
+		cols:   cols,
+		stride: stride,
+		words:  make([]uint64, rows*stride),
+	}
+}
+
+func (b *bitGrid) clone() *bitGrid {
+	c := *b
+	c.words = make([]uint64, len(b.words))
+	copy(c.words, b.words)
+	return &c
+}
+
+func (b *bitGrid) get(x, y int) bool {
+	if x < 0 || x >= b.cols || y < 0 || y >= b.rows {
+		return false
+	}
+	return b.words[y*b.stride+x/64]&(1<<uint(x%64)) != 0
+}
+
+func (b *bitGrid) set(x, y int, alive bool) {
+	if x < 0 || x >= b.cols || y < 0 || y >= b.rows {
+		return
+	}
+	if alive {
+		b.words[y*b.stride+x/64] |= 1 << uint(x%64)
+	} else {
+		b.words[y*b.stride+x/64] &^= 1 << uint(x%64)
+	}
+}
+
+func (b *bitGrid) clear() {
+	for i := range b.words {
+		b.words[i] = 0
+	}
+}
+
+func (b *bitGrid) row(y int) []uint64 {
+	return b.words[y*b.stride : (y+1)*b.stride]
+}
+
+func (b *bitGrid) population() int {
+	n := 0
+	for _, w := range b.words {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// lastMask has a bit set for every real column in the last word of a row.
+func (b *bitGrid) lastMask() uint64 {
+	if b.cols%64 == 0 {
+		return ^uint64(0)
+	}
+	return 1<<uint(b.cols%64) - 1
+}
+
+// step writes the live cells of the generation after b into next under rule.
+// Cells set in dying are in a refractory state of a Generations rule and are
+// not born. What lies past the edges of the grid is decided by topology.
+//
+// Neighbours are counted 64 cells at a time: the eight neighbour words of a
+// word are added into a counter and the rule is then applied with plain
+// boolean operations on its bit planes.
+func (b *bitGrid) step(next *bitGrid, rule Rule, dying *bitGrid, topology Topology) {
+	b.stepRows(next, rule, dying, topology, 0, b.rows)
+}
+
+// stepRows is step for the rows y0 <= y < y1 only. It reads b and the same
+// rows of dying and writes the same rows of next, so bands of rows can be
+// stepped at the same time.
+func (b *bitGrid) stepRows(next *bitGrid, rule Rule, dying *bitGrid, topology Topology, y0, y1 int) {
+	lastMask := b.lastMask()
+	lastBit := uint((b.cols - 1) % 64)
+	scratch := [2][]uint64{make([]uint64, b.stride), make([]uint64, b.stride)}
+
+	for y := y0; y < y1; y++ {
+		up := b.halo(y-1, topology, scratch[0])
+		mid := b.halo(y, topology, nil)
+		down := b.halo(y+1, topology, scratch[1])
+		blocked := dying.row(y)
+		out := next.row(y)
+
+		for i := range mid.words {
+			var n counter
+			n.add(west(up.words, i, up.west))
+			n.add(up.words[i])
+			n.add(east(up.words, i, up.east, lastBit))
+			n.add(west(mid.words, i, mid.west))
+			n.add(east(mid.words, i, mid.east, lastBit))
+			n.add(west(down.words, i, down.west))
+			n.add(down.words[i])
+			n.add(east(down.words, i, down.east, lastBit))
+
+			w := rule.apply(&n, mid.words[i], blocked[i])
+			if i == len(mid.words)-1 {
+				w &= lastMask
+			}
+			out[i] = w
+		}
+	}
+}
+
+// haloRow is a row of cells together with the cells just past its west and
+// east ends.
+type haloRow struct {
+	words []uint64
+	west  uint64
+	east  uint64
+}
+
+// halo returns row y, which may lie above or below the grid, as seen through
+// topology. Rows that have to be mirrored are built in scratch.
+func (b *bitGrid) halo(y int, topology Topology, scratch []uint64) haloRow {
+	h := haloRow{
+		west: b.getWrapped(-1, y, topology),
+		east: b.getWrapped(b.cols, y, topology),
+	}
+	if y >= 0 && y < b.rows {
+		h.words = b.row(y)
+		return h
+	}
+
+	for i := range scratch {
+		scratch[i] = 0
+	}
+	h.words = scratch
+
+	// Find which row this stands for and whether it is mirrored by looking
+	// at where both of its ends land.
+	x0, y0, ok := topology.Wrap(0, y, b.rows, b.cols)
+	if !ok {
+		return h
+	}
+	x1, _, _ := topology.Wrap(b.cols-1, y, b.rows, b.cols)
+	if x0 < x1 {
+		copy(scratch, b.row(y0))
+		return h
+	}
+	for i, w := range b.row(y0) {
+		for ; w != 0; w &= w - 1 {
+			x := b.cols - 1 - (i*64 + bits.TrailingZeros64(w))
+			scratch[x/64] |= 1 << uint(x%64)
+		}
+	}
+	return h
+}
+
+// getWrapped returns the cell at x, y as 1 or 0, following topology for
+// cells past the edge of the grid.
+func (b *bitGrid) getWrapped(x, y int, topology Topology) uint64 {
+	x, y, ok := topology.Wrap(x, y, b.rows, b.cols)
+	if ok && b.get(x, y) {
+		return 1
+	}
+	return 0
+}
+
+// counter holds a neighbour count for each of the 64 cells of a word as four
+// bit planes: b0 is the ones bit of every count, b3 the eights bit.
+type counter struct {
+	b0, b1, b2, b3 uint64
+}
+
+// add adds one to the count of every cell whose bit is set in n.
+func (c *counter) add(n uint64) {
+	carry := c.b0 & n
+	c.b0 ^= n
+	carry2 := c.b1 & carry
+	c.b1 ^= carry
+	carry3 := c.b2 & carry2
+	c.b2 ^= carry2
+	c.b3 |= carry3
+}
+
+// equals returns the cells whose count is exactly k.
+func (c *counter) equals(k int) uint64 {
+	m := ^uint64(0)
+	for i, plane := range [4]uint64{c.b0, c.b1, c.b2, c.b3} {
+		if k&(1<<uint(i)) != 0 {
+			m &= plane
+		} else {
+			m &^= plane
+		}
+	}
+	return m
+}
+
+// west returns word i of row shifted so that every bit holds the cell to its
+// west. ghost is the cell west of column 0.
+func west(row []uint64, i int, ghost uint64) uint64 {
+	w := row[i] << 1
+	if i > 0 {
+		w |= row[i-1] >> 63
+	} else {
+		w |= ghost
+	}
+	return w
+}
+
+// east returns word i of row shifted so that every bit holds the cell to its
+// east. ghost is the cell east of the last column, which sits at lastBit of
+// the last word.
+func east(row []uint64, i int, ghost uint64, lastBit uint) uint64 {
+	e := row[i] >> 1
+	if i+1 < len(row) {
+		e |= row[i+1] << 63
+	} else {
+		e |= ghost << lastBit
+	}
+	return e
+}
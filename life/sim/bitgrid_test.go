@@ -0,0 +1,94 @@
+package sim
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// mapCell and mapGrid are the map of live cells the board was kept in before
+// the bit grid, kept as a reference to check and time the bit grid against.
+type mapCell struct {
+	x, y int
+}
+
+type mapGrid struct {
+	rows, cols int
+	live       map[mapCell]bool
+}
+
+// step runs a generation of Conway's rule on a bounded board, looking up
+// the eight neighbours of every cell in the map.
+func (g *mapGrid) step() {
+	next := make(map[mapCell]bool)
+	for y := 0; y < g.rows; y++ {
+		for x := 0; x < g.cols; x++ {
+			n := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if (dx != 0 || dy != 0) && g.live[mapCell{x + dx, y + dy}] {
+						n++
+					}
+				}
+			}
+			if n == 3 || n == 2 && g.live[mapCell{x, y}] {
+				next[mapCell{x, y}] = true
+			}
+		}
+	}
+	g.live = next
+}
+
+// randomSoup returns a rows x cols bit grid with about half its cells alive,
+// the same for the same seed, and the same cells as a mapGrid.
+func randomSoup(rows, cols int, seed int64) (*bitGrid, *mapGrid) {
+	rng := rand.New(rand.NewSource(seed))
+	b := newBitGrid(rows, cols)
+	m := &mapGrid{rows: rows, cols: cols, live: make(map[mapCell]bool)}
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			if rng.Intn(2) == 0 {
+				b.set(x, y, true)
+				m.live[mapCell{x, y}] = true
+			}
+		}
+	}
+	return b, m
+}
+
+func TestBitGridMatchesMap(t *testing.T) {
+	// 130 columns leave a part word at the end of every row.
+	b, m := randomSoup(70, 130, 1)
+	next, dying := newBitGrid(b.rows, b.cols), newBitGrid(b.rows, b.cols)
+	for gen := 1; gen <= 50; gen++ {
+		b.step(next, Conway, dying, Bounded)
+		b, next = next, b
+		m.step()
+
+		if b.population() != len(m.live) {
+			t.Fatalf("generation %d: population %d, want %d", gen, b.population(), len(m.live))
+		}
+		for c := range m.live {
+			if !b.get(c.x, c.y) {
+				t.Fatalf("generation %d: cell %d,%d is dead, want alive", gen, c.x, c.y)
+			}
+		}
+	}
+}
+
+func BenchmarkBitGridStep(b *testing.B) {
+	g, _ := randomSoup(2000, 2000, 1)
+	next, dying := newBitGrid(g.rows, g.cols), newBitGrid(g.rows, g.cols)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.step(next, Conway, dying, Bounded)
+		g, next = next, g
+	}
+}
+
+func BenchmarkMapStep(b *testing.B) {
+	_, m := randomSoup(2000, 2000, 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.step()
+	}
+}
@@ -0,0 +1,444 @@
+package sim
+
+// HashLife stores the universe as a quadtree in which equal subtrees are the
+// same node, and remembers for every node what its centre looks like some
+// generations later. Patterns with a lot of repetition in space and time,
+// such as guns and breeders, can then be advanced by millions of
+// generations in one go.
+//
+// Only two-state rules without B0 can be run this way.
+
+// hlNode is a square of 2^level x 2^level cells. Nodes are never changed once
+// made, and equal squares are always the same node, so nodes can be compared
+// with ==.
+type hlNode struct {
+	nw, ne, sw, se *hlNode
+
+	level      int
+	population int
+	// hash depends only on the cells of the node.
+	hash uint64
+
+	// result is the centre half of the node, 2^resultStep generations
+	// later under resultRule. resultStep is -1 until it has been worked
+	// out.
+	result     *hlNode
+	resultStep int
+	resultRule *hlRule
+}
+
+// hlRule is the rule results are worked out under. A HashLife and its clones
+// share their nodes, and with them the result each node remembers, so a
+// result is only used by a universe that has the same hlRule; switching rules
+// makes a new one rather than changing it.
+type hlRule struct {
+	rule Rule
+}
+
+type hlKey struct {
+	nw, ne, sw, se *hlNode
+}
+
+const (
+	// hashLifeMaxNodes is how many nodes are kept before unreachable ones
+	// and the remembered results are thrown away.
+	hashLifeMaxNodes = 1 << 20
+	// hlNodeBytes is roughly what a node takes with its entry in the table.
+	hlNodeBytes = 144
+)
+
+// HashLife is an unbounded universe run with the HashLife algorithm. The
+// root node is centred on the origin: a root of level L covers the cells
+// from -2^(L-1) up to but not including 2^(L-1) on both axes.
+type HashLife struct {
+	root  *hlNode
+	table map[hlKey]*hlNode
+
+	dead  *hlNode
+	alive *hlNode
+	// empty holds the empty node of every level made so far.
+	empty []*hlNode
+
+	// rule is the rule the results are worked out under.
+	rule *hlRule
+
+	// made counts the nodes made so far, and cloned is what it was at the
+	// last clone. pinned is how many nodes were made between the clone
+	// before this one and this one, which the clone keeps alive once the
+	// universe it was cloned from has moved on and collected them.
+	made   int
+	cloned int
+	pinned int
+}
+
+func NewHashLife() *HashLife {
+	h := &HashLife{
+		table: make(map[hlKey]*hlNode),
+		dead:  &hlNode{resultStep: -1},
+		alive: &hlNode{population: 1, hash: 1, resultStep: -1},
+		rule:  &hlRule{},
+	}
+	h.empty = []*hlNode{h.dead}
+	h.root = h.emptyNode(3)
+	return h
+}
+
+// HashLifeSupports reports whether rule can be run with HashLife.
+func HashLifeSupports(rule Rule) bool {
+	return rule.states == 2 && rule.birth&1 == 0
+}
+
+// node returns the node with the given quadrants.
+func (h *HashLife) node(nw, ne, sw, se *hlNode) *hlNode {
+	k := hlKey{nw, ne, sw, se}
+	if n, ok := h.table[k]; ok {
+		return n
+	}
+	n := &hlNode{
+		nw: nw, ne: ne, sw: sw, se: se,
+		level:      nw.level + 1,
+		population: nw.population + ne.population + sw.population + se.population,
+		hash:       mixHash(mixHash(mixHash(mixHash(uint64(nw.level+1), nw.hash), ne.hash), sw.hash), se.hash),
+		resultStep: -1,
+	}
+	h.table[k] = n
+	h.made++
+	return n
+}
+
+func (h *HashLife) emptyNode(level int) *hlNode {
+	for len(h.empty) <= level {
+		e := h.empty[len(h.empty)-1]
+		h.empty = append(h.empty, h.node(e, e, e, e))
+	}
+	return h.empty[level]
+}
+
+// expand returns a node one level up with n in its centre.
+func (h *HashLife) expand(n *hlNode) *hlNode {
+	e := h.emptyNode(n.level - 1)
+	return h.node(
+		h.node(e, e, e, n.nw),
+		h.node(e, e, n.ne, e),
+		h.node(e, n.sw, e, e),
+		h.node(n.se, e, e, e),
+	)
+}
+
+// centre returns the centre half of n.
+func (h *HashLife) centre(n *hlNode) *hlNode {
+	return h.node(n.nw.se, n.ne.sw, n.sw.ne, n.se.nw)
+}
+
+// successor returns the centre half of n advanced by 2^j generations, where
+// j is at most n.level-2.
+func (h *HashLife) successor(n *hlNode, j int) *hlNode {
+	if n.population == 0 {
+		return h.emptyNode(n.level - 1)
+	}
+	if n.resultStep == j && n.resultRule == h.rule {
+		return n.result
+	}
+
+	var r *hlNode
+	if n.level == 2 {
+		r = h.base(n)
+	} else {
+		// The nine overlapping squares of half the size of n.
+		n00, n01, n02 := n.nw, h.node(n.nw.ne, n.ne.nw, n.nw.se, n.ne.sw), n.ne
+		n10, n11, n12 := h.node(n.nw.sw, n.nw.se, n.sw.nw, n.sw.ne), h.centre(n), h.node(n.ne.sw, n.ne.se, n.se.nw, n.se.ne)
+		n20, n21, n22 := n.sw, h.node(n.sw.ne, n.se.nw, n.sw.se, n.se.sw), n.se
+
+		// At full speed both halves of the jump advance the pattern.
+		// Slower jumps take the centres as they are the first time round.
+		half := func(m *hlNode) *hlNode { return h.centre(m) }
+		if j == n.level-2 {
+			half = func(m *hlNode) *hlNode { return h.successor(m, j-1) }
+		}
+		c00, c01, c02 := half(n00), half(n01), half(n02)
+		c10, c11, c12 := half(n10), half(n11), half(n12)
+		c20, c21, c22 := half(n20), half(n21), half(n22)
+
+		j2 := j
+		if j == n.level-2 {
+			j2 = j - 1
+		}
+		r = h.node(
+			h.successor(h.node(c00, c01, c10, c11), j2),
+			h.successor(h.node(c01, c02, c11, c12), j2),
+			h.successor(h.node(c10, c11, c20, c21), j2),
+			h.successor(h.node(c11, c12, c21, c22), j2),
+		)
+	}
+
+	n.result, n.resultStep, n.resultRule = r, j, h.rule
+	return r
+}
+
+// base advances the centre 2x2 cells of a 4x4 node by one generation.
+func (h *HashLife) base(n *hlNode) *hlNode {
+	var cells [4][4]bool
+	for i, q := range [4]*hlNode{n.nw, n.ne, n.sw, n.se} {
+		ox, oy := i%2*2, i/2*2
+		cells[oy][ox] = q.nw == h.alive
+		cells[oy][ox+1] = q.ne == h.alive
+		cells[oy+1][ox] = q.sw == h.alive
+		cells[oy+1][ox+1] = q.se == h.alive
+	}
+
+	next := func(x, y int) *hlNode {
+		count := 0
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if (dx != 0 || dy != 0) && cells[y+dy][x+dx] {
+					count++
+				}
+			}
+		}
+		mask := h.rule.rule.birth
+		if cells[y][x] {
+			mask = h.rule.rule.survive
+		}
+		if mask&(1<<uint(count)) != 0 {
+			return h.alive
+		}
+		return h.dead
+	}
+	return h.node(next(1, 1), next(2, 1), next(1, 2), next(2, 2))
+}
+
+// Jump advances the universe by 2^j generations. It panics when rule cannot
+// be run with HashLife, see HashLifeSupports.
+func (h *HashLife) Jump(rule Rule, j int) {
+	if !HashLifeSupports(rule) {
+		panic("sim: HashLife cannot run " + rule.String())
+	}
+	if !rule.sameAs(h.rule.rule) {
+		h.rule = &hlRule{rule: rule}
+		h.collect()
+	} else if len(h.table) > hashLifeMaxNodes {
+		h.collect()
+	}
+
+	// Grow the root until the pattern sits in its centre half and then
+	// once more, so that nothing can reach past the part successor keeps.
+	for h.root.level < j+2 || h.centre(h.root).population != h.root.population {
+		h.root = h.expand(h.root)
+	}
+	h.root = h.expand(h.root)
+	h.root = h.successor(h.root, j)
+}
+
+// collect drops the nodes that cannot be reached from the root and forgets
+// every remembered result.
+func (h *HashLife) collect() {
+	h.table = make(map[hlKey]*hlNode, len(h.table)/2)
+
+	var keep func(n *hlNode)
+	keep = func(n *hlNode) {
+		n.result, n.resultStep, n.resultRule = nil, -1, nil
+		if n.level == 0 {
+			return
+		}
+		k := hlKey{n.nw, n.ne, n.sw, n.se}
+		if _, ok := h.table[k]; ok {
+			return
+		}
+		h.table[k] = n
+		keep(n.nw)
+		keep(n.ne)
+		keep(n.sw)
+		keep(n.se)
+	}
+	keep(h.root)
+	for _, e := range h.empty {
+		keep(e)
+	}
+}
+
+func (h *HashLife) Step(rule Rule) {
+	h.Jump(rule, 0)
+}
+
+func (h *HashLife) Cell(x, y int) uint8 {
+	n := h.root
+	half := 1 << uint(n.level-1)
+	x, y = x+half, y+half
+	if x < 0 || x >= 2*half || y < 0 || y >= 2*half {
+		return 0
+	}
+
+	for n.level > 0 && n.population > 0 {
+		half = 1 << uint(n.level-1)
+		switch {
+		case x < half && y < half:
+			n = n.nw
+		case y < half:
+			n, x = n.ne, x-half
+		case x < half:
+			n, y = n.sw, y-half
+		default:
+			n, x, y = n.se, x-half, y-half
+		}
+	}
+	if n == h.alive {
+		return 1
+	}
+	return 0
+}
+
+func (h *HashLife) SetCell(x, y int, state uint8) {
+	for {
+		half := 1 << uint(h.root.level-1)
+		if x >= -half && x < half && y >= -half && y < half {
+			break
+		}
+		h.root = h.expand(h.root)
+	}
+
+	half := 1 << uint(h.root.level-1)
+	h.root = h.set(h.root, x+half, y+half, state == 1)
+}
+
+// set returns n with the cell at x, y, counted from its top left corner,
+// changed.
+func (h *HashLife) set(n *hlNode, x, y int, alive bool) *hlNode {
+	if n.level == 0 {
+		if alive {
+			return h.alive
+		}
+		return h.dead
+	}
+
+	half := 1 << uint(n.level-1)
+	switch {
+	case x < half && y < half:
+		return h.node(h.set(n.nw, x, y, alive), n.ne, n.sw, n.se)
+	case y < half:
+		return h.node(n.nw, h.set(n.ne, x-half, y, alive), n.sw, n.se)
+	case x < half:
+		return h.node(n.nw, n.ne, h.set(n.sw, x, y-half, alive), n.se)
+	}
+	return h.node(n.nw, n.ne, n.sw, h.set(n.se, x-half, y-half, alive))
+}
+
+func (h *HashLife) Clear() {
+	h.root = h.emptyNode(3)
+}
+
+// Clone shares the nodes of h, which never change, so it only copies the
+// root. Results the nodes remember for one rule are not used under another,
+// so stepping either universe under any rule leaves the other as it was.
+func (h *HashLife) Clone() Universe {
+	c := *h
+	c.pinned = h.made - h.cloned
+	h.cloned = h.made
+	c.cloned = c.made
+	return &c
+}
+
+// MemSize counts the nodes made since the clone before this one, which are
+// the ones the clone keeps alive that earlier clones do not.
+func (h *HashLife) MemSize() int {
+	return 64 + h.pinned*hlNodeBytes
+}
+
+func (h *HashLife) Population() int {
+	return h.root.population
+}
+
+// Hash is the hash of the smallest node around the origin that holds every
+// live cell, which does not depend on how far the root has been expanded.
+func (h *HashLife) Hash() uint64 {
+	n := h.root
+	for n.level > 3 && h.centre(n).population == n.population {
+		n = h.centre(n)
+	}
+	return n.hash
+}
+
+// Changes is not kept count of, a step may span many generations.
+func (h *HashLife) Changes() (births, deaths int, ok bool) {
+	return 0, 0, false
+}
+
+func (h *HashLife) Bounds() (minX, minY, maxX, maxY int, ok bool) {
+	if h.root.population == 0 {
+		return 0, 0, 0, 0, false
+	}
+
+	// Every side is found by walking down the tree, looking at the half
+	// of each node nearest that side first. Results are remembered per
+	// node since the same node turns up in many places.
+	west := func(n *hlNode) [2]*hlNode { return [2]*hlNode{n.nw, n.sw} }
+	east := func(n *hlNode) [2]*hlNode { return [2]*hlNode{n.ne, n.se} }
+	north := func(n *hlNode) [2]*hlNode { return [2]*hlNode{n.nw, n.ne} }
+	south := func(n *hlNode) [2]*hlNode { return [2]*hlNode{n.sw, n.se} }
+
+	// edge returns how far in from the near side the first live cell of
+	// n is.
+	edge := func(near, far func(n *hlNode) [2]*hlNode) func(n *hlNode) int {
+		memo := make(map[*hlNode]int)
+		var f func(n *hlNode) int
+		f = func(n *hlNode) int {
+			if n.level == 0 {
+				return 0
+			}
+			if d, ok := memo[n]; ok {
+				return d
+			}
+			d := -1
+			for _, q := range near(n) {
+				if q.population > 0 {
+					if e := f(q); d < 0 || e < d {
+						d = e
+					}
+				}
+			}
+			if d < 0 {
+				half := 1 << uint(n.level-1)
+				for _, q := range far(n) {
+					if q.population > 0 {
+						if e := f(q) + half; d < 0 || e < d {
+							d = e
+						}
+					}
+				}
+			}
+			memo[n] = d
+			return d
+		}
+		return f
+	}
+
+	half := 1 << uint(h.root.level-1)
+	minX = edge(west, east)(h.root) - half
+	maxX = half - 1 - edge(east, west)(h.root)
+	minY = edge(north, south)(h.root) - half
+	maxY = half - 1 - edge(south, north)(h.root)
+	return minX, minY, maxX, maxY, true
+}
+
+func (h *HashLife) ForEachIn(x0, y0, x1, y1 int, fn func(x, y int, state uint8)) {
+	half := 1 << uint(h.root.level-1)
+	h.forEachInNode(h.root, -half, -half, x0, y0, x1, y1, fn)
+}
+
+// forEachInNode calls fn for the live cells of n, whose top left corner is
+// at ox, oy, that lie within x0, y0, x1, y1.
+func (h *HashLife) forEachInNode(n *hlNode, ox, oy, x0, y0, x1, y1 int, fn func(x, y int, state uint8)) {
+	size := 1 << uint(n.level)
+	if n.population == 0 || ox >= x1 || oy >= y1 || ox+size <= x0 || oy+size <= y0 {
+		return
+	}
+	if n.level == 0 {
+		fn(ox, oy, 1)
+		return
+	}
+
+	half := size / 2
+	h.forEachInNode(n.nw, ox, oy, x0, y0, x1, y1, fn)
+	h.forEachInNode(n.ne, ox+half, oy, x0, y0, x1, y1, fn)
+	h.forEachInNode(n.sw, ox, oy+half, x0, y0, x1, y1, fn)
+	h.forEachInNode(n.se, ox+half, oy+half, x0, y0, x1, y1, fn)
+}
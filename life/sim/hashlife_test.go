@@ -0,0 +1,143 @@
+package sim
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Patterns are drawn with O for live cells and . for dead ones.
+var (
+	rPentomino = []string{
+		".OO",
+		"OO.",
+		".O.",
+	}
+	acorn = []string{
+		".O.....",
+		"...O...",
+		"OO..OOO",
+	}
+	gosperGun = []string{
+		"........................O...........",
+		"......................O.O...........",
+		"............OO......OO............OO",
+		"...........O...O....OO............OO",
+		"OO........O.....O...OO..............",
+		"OO........O...O.OO....O.O...........",
+		"..........O.....O.......O...........",
+		"...........O...O....................",
+		"............OO......................",
+	}
+)
+
+// place sets the live cells of pattern in u with its top left corner at x, y.
+func place(u Universe, pattern []string, x, y int) {
+	for dy, row := range pattern {
+		for dx, ch := range row {
+			if ch == 'O' {
+				u.SetCell(x+dx, y+dy, 1)
+			}
+		}
+	}
+}
+
+// cellsOf returns the cells of u that are not dead, by position.
+func cellsOf(u Universe) map[[2]int]uint8 {
+	cells := make(map[[2]int]uint8)
+	if minX, minY, maxX, maxY, ok := u.Bounds(); ok {
+		u.ForEachIn(minX, minY, maxX+1, maxY+1, func(x, y int, state uint8) {
+			cells[[2]int{x, y}] = state
+		})
+	}
+	return cells
+}
+
+// sameCells reports the first difference between got and want, or "" when
+// they hold the same cells.
+func sameCells(got, want Universe) string {
+	g, w := cellsOf(got), cellsOf(want)
+	if len(g) != len(w) {
+		return fmt.Sprintf("%d cells, want %d", len(g), len(w))
+	}
+	for c, s := range w {
+		if g[c] != s {
+			return fmt.Sprintf("cell %d,%d is %d, want %d", c[0], c[1], g[c], s)
+		}
+	}
+	return ""
+}
+
+// TestHashLifeMatchesSparse jumps patterns by several powers of two and
+// checks every jump against the sparse engine stepped one generation at a
+// time.
+func TestHashLifeMatchesSparse(t *testing.T) {
+	patterns := []struct {
+		name  string
+		cells []string
+	}{
+		{"R-pentomino", rPentomino},
+		{"Acorn", acorn},
+		{"Gosper glider gun", gosperGun},
+	}
+	for _, p := range patterns {
+		for j := 0; j <= 6; j += 2 {
+			t.Run(fmt.Sprintf("%s/2^%d", p.name, j), func(t *testing.T) {
+				h, want := NewHashLife(), NewSparse()
+				place(h, p.cells, -5, -3)
+				place(want, p.cells, -5, -3)
+				for jump := 1; jump <= 4; jump++ {
+					h.Jump(Conway, j)
+					for i := 0; i < 1<<j; i++ {
+						want.Step(Conway)
+					}
+					if diff := sameCells(h, want); diff != "" {
+						t.Fatalf("after %d generations: %s", jump<<j, diff)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestHashLifeRefusesUnsupportedRules(t *testing.T) {
+	for _, s := range []string{"B2/S/C3", "B03/S23"} {
+		rule, err := ParseRule(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: Jump did not panic", s)
+				}
+			}()
+			NewHashLife().Jump(rule, 0)
+		}()
+	}
+}
+
+// TestHashLifeCloneKeepsItsRule steps a clone under Conway's rule after the
+// universe it was cloned from, which shares its nodes, ran under another.
+func TestHashLifeCloneKeepsItsRule(t *testing.T) {
+	seeds, err := ParseRule("B2/S")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, want := NewHashLife(), NewSparse()
+	place(h, rPentomino, 0, 0)
+	place(want, rPentomino, 0, 0)
+	h.Jump(Conway, 1)
+	want.Step(Conway)
+	want.Step(Conway)
+
+	c := h.Clone().(*HashLife)
+	for i := 0; i < 4; i++ {
+		h.Jump(seeds, 1)
+	}
+	c.Jump(Conway, 1)
+	want.Step(Conway)
+	want.Step(Conway)
+	if diff := sameCells(c, want); diff != "" {
+		t.Fatal(diff)
+	}
+}
@@ -0,0 +1,207 @@
+package sim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rule is a Life-like or Generations rule. birth and survive are bitmasks
+// over neighbour counts: bit n of birth is set when a dead cell with n live
+// neighbours comes alive, bit n of survive when a live cell with n live
+// neighbours stays alive.
+//
+// states is the number of cell states. Life-like rules have two, dead (0) and
+// alive (1). Generations rules have more: a live cell that does not survive
+// moves to state 2 and then one state further every generation until it
+// wraps back to dead. Only live cells count as neighbours and cells that are
+// still dying cannot be born.
+type Rule struct {
+	name    string
+	birth   uint16
+	survive uint16
+	states  int
+}
+
+var Conway = Rule{name: "Conway", birth: 1 << 3, survive: 1<<2 | 1<<3, states: 2}
+
+// ruleCatalogue lists the rules that can be picked by name and cycled through
+// from the keyboard.
+var ruleCatalogue = []Rule{
+	Conway,
+	mustParseRule("HighLife", "B36/S23"),
+	mustParseRule("Seeds", "B2/S"),
+	mustParseRule("Day & Night", "B3678/S34678"),
+	mustParseRule("Life without Death", "B3/S012345678"),
+	mustParseRule("2x2", "B36/S125"),
+	mustParseRule("Morley", "B368/S245"),
+	mustParseRule("Diamoeba", "B35678/S5678"),
+	mustParseRule("Replicator", "B1357/S1357"),
+	mustParseRule("Brian's Brain", "B2/S/C3"),
+	mustParseRule("Star Wars", "B2/S345/C4"),
+	mustParseRule("Sticks", "B2/S3456/C6"),
+	mustParseRule("Frogs", "B34/S12/C3"),
+}
+
+// ParseRule parses a rule in B/S notation ("B36/S23", "S23/B36"), in the
+// older S/B notation ("23/36") or by its name in the catalogue ("highlife").
+// Generations rules add the number of states as a third part ("B2/S/C3",
+// "345/2/4").
+func ParseRule(s string) (Rule, error) {
+	s = strings.TrimSpace(s)
+	for _, r := range ruleCatalogue {
+		if strings.EqualFold(r.name, s) {
+			return r, nil
+		}
+	}
+
+	r, err := parseNotation(s)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	// Give well known rules their name even when they were typed out.
+	for _, known := range ruleCatalogue {
+		if known.sameAs(r) {
+			r.name = known.name
+		}
+	}
+	return r, nil
+}
+
+// parseNotation parses a rule written in B/S or S/B notation.
+func parseNotation(s string) (Rule, error) {
+	parts := strings.Split(strings.ReplaceAll(s, " ", ""), "/")
+	if len(parts) != 2 && len(parts) != 3 {
+		return Rule{}, fmt.Errorf("rule %q: want two or three parts separated by '/'", s)
+	}
+
+	r := Rule{states: 2}
+	if len(parts) == 3 {
+		c := strings.TrimLeft(strings.ToUpper(parts[2]), "CG")
+		states, err := strconv.Atoi(c)
+		if err != nil || states < 2 || states > 256 {
+			return Rule{}, fmt.Errorf("rule %q: invalid number of states %q", s, parts[2])
+		}
+		r.states = states
+	}
+
+	var err error
+	first, second := strings.ToUpper(parts[0]), strings.ToUpper(parts[1])
+	switch {
+	case strings.HasPrefix(first, "B") && strings.HasPrefix(second, "S"):
+		r.birth, err = parseCounts(first[1:])
+		if err == nil {
+			r.survive, err = parseCounts(second[1:])
+		}
+	case strings.HasPrefix(first, "S") && strings.HasPrefix(second, "B"):
+		r.survive, err = parseCounts(first[1:])
+		if err == nil {
+			r.birth, err = parseCounts(second[1:])
+		}
+	default:
+		r.survive, err = parseCounts(first)
+		if err == nil {
+			r.birth, err = parseCounts(second)
+		}
+	}
+	if err != nil {
+		return Rule{}, fmt.Errorf("rule %q: %w", s, err)
+	}
+	return r, nil
+}
+
+func mustParseRule(name, s string) Rule {
+	r, err := parseNotation(s)
+	if err != nil {
+		panic(err)
+	}
+	r.name = name
+	return r
+}
+
+// parseCounts turns a string of neighbour counts like "236" into a bitmask.
+func parseCounts(s string) (uint16, error) {
+	var mask uint16
+	for _, ch := range s {
+		if ch < '0' || ch > '8' {
+			return 0, fmt.Errorf("invalid neighbour count %q", ch)
+		}
+		mask |= 1 << uint(ch-'0')
+	}
+	return mask, nil
+}
+
+// String returns the rule in B/S notation, with a /C suffix for
+// Generations rules.
+func (r Rule) String() string {
+	var sb strings.Builder
+	sb.WriteByte('B')
+	writeCounts(&sb, r.birth)
+	sb.WriteString("/S")
+	writeCounts(&sb, r.survive)
+	if r.states > 2 {
+		fmt.Fprintf(&sb, "/C%d", r.states)
+	}
+	return sb.String()
+}
+
+// States returns the number of cell states, two for Life-like rules.
+func (r Rule) States() int {
+	return r.states
+}
+
+// sameAs reports whether r and o evolve cells the same way, whatever their
+// names.
+func (r Rule) sameAs(o Rule) bool {
+	return r.birth == o.birth && r.survive == o.survive && r.states == o.states
+}
+
+func writeCounts(sb *strings.Builder, mask uint16) {
+	for n := 0; n <= 8; n++ {
+		if mask&(1<<uint(n)) != 0 {
+			sb.WriteByte(byte('0' + n))
+		}
+	}
+}
+
+// Label is the text shown for the rule on screen.
+func (r Rule) Label() string {
+	if r.name == "" {
+		return r.String()
+	}
+	return r.name + " (" + r.String() + ")"
+}
+
+// Next returns the rule after r in the catalogue. Rules that are not in the
+// catalogue are followed by its first entry.
+func (r Rule) Next() Rule {
+	for i, known := range ruleCatalogue {
+		if known.sameAs(r) {
+			return ruleCatalogue[(i+1)%len(ruleCatalogue)]
+		}
+	}
+	return ruleCatalogue[0]
+}
+
+// apply returns which of the 64 cells in alive are alive in the next
+// generation given their neighbour counts. Cells set in blocked are dying
+// and cannot be born.
+func (r Rule) apply(n *counter, alive, blocked uint64) uint64 {
+	var born, survive uint64
+	for k := 0; k <= 8; k++ {
+		b := r.birth&(1<<uint(k)) != 0
+		s := r.survive&(1<<uint(k)) != 0
+		if !b && !s {
+			continue
+		}
+		m := n.equals(k)
+		if b {
+			born |= m
+		}
+		if s {
+			survive |= m
+		}
+	}
+	return alive&survive | ^alive&^blocked&born
+}
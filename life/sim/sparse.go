@@ -0,0 +1,365 @@
+package sim
+
+import "math/bits"
+
+// tileSize is the width and height of a tile. A tile row is one word.
+const tileSize = 64
+
+// tile is a 64x64 block of an unbounded universe. Bit i of live[y] is the
+// cell at x = i, y within the tile.
+type tile struct {
+	live  [tileSize]uint64
+	dying [tileSize]uint64
+	// decay holds the states of dying cells, indexed by y*tileSize+x. It is
+	// only allocated once a cell of the tile starts dying.
+	decay *[tileSize * tileSize]uint8
+}
+
+func (t *tile) empty() bool {
+	for y := range t.live {
+		if t.live[y]|t.dying[y] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *tile) hasLive() bool {
+	for _, w := range t.live {
+		if w != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// tileKey is the position of a tile: the tile at x, y covers the cells from
+// x*64, y*64 up to but not including (x+1)*64, (y+1)*64.
+type tileKey struct {
+	x, y int
+}
+
+// tileAt returns the key of the tile holding the cell at x, y and the
+// position of the cell within it.
+func tileAt(x, y int) (k tileKey, tx, ty int) {
+	return tileKey{x >> 6, y >> 6}, x & (tileSize - 1), y & (tileSize - 1)
+}
+
+// sparseUniverse is an unbounded universe. Only the tiles that hold cells
+// which are not dead are stored, so it grows with the pattern rather than
+// with the area the pattern covers.
+//
+// Cells are only born next to tiles holding live cells, so rules with B0,
+// under which empty space comes alive, do not fill the whole plane.
+type sparseUniverse struct {
+	tiles map[tileKey]*tile
+
+	// next and candidates are kept between steps to save allocations.
+	next       map[tileKey]*tile
+	candidates map[tileKey]bool
+	free       []*tile
+
+	births int
+	deaths int
+}
+
+// NewSparse returns an empty unbounded universe.
+func NewSparse() Universe {
+	return newSparseUniverse()
+}
+
+func newSparseUniverse() *sparseUniverse {
+	return &sparseUniverse{
+		tiles:      make(map[tileKey]*tile),
+		next:       make(map[tileKey]*tile),
+		candidates: make(map[tileKey]bool),
+	}
+}
+
+func (u *sparseUniverse) Cell(x, y int) uint8 {
+	k, tx, ty := tileAt(x, y)
+	t := u.tiles[k]
+	switch {
+	case t == nil:
+		return 0
+	case t.live[ty]&(1<<uint(tx)) != 0:
+		return 1
+	case t.dying[ty]&(1<<uint(tx)) != 0:
+		return t.decay[ty*tileSize+tx]
+	}
+	return 0
+}
+
+func (u *sparseUniverse) SetCell(x, y int, state uint8) {
+	k, tx, ty := tileAt(x, y)
+	t := u.tiles[k]
+	if t == nil {
+		if state == 0 {
+			return
+		}
+		t = u.alloc()
+		u.tiles[k] = t
+	}
+
+	bit := uint64(1) << uint(tx)
+	t.live[ty] &^= bit
+	t.dying[ty] &^= bit
+	switch {
+	case state == 1:
+		t.live[ty] |= bit
+	case state >= 2:
+		t.dying[ty] |= bit
+		if t.decay == nil {
+			t.decay = new([tileSize * tileSize]uint8)
+		}
+		t.decay[ty*tileSize+tx] = state
+	}
+
+	if state == 0 && t.empty() {
+		delete(u.tiles, k)
+		u.release(t)
+	}
+}
+
+func (u *sparseUniverse) Clear() {
+	for k, t := range u.tiles {
+		delete(u.tiles, k)
+		u.release(t)
+	}
+}
+
+func (u *sparseUniverse) alloc() *tile {
+	if n := len(u.free); n > 0 {
+		t := u.free[n-1]
+		u.free = u.free[:n-1]
+		return t
+	}
+	return &tile{}
+}
+
+func (u *sparseUniverse) release(t *tile) {
+	*t = tile{}
+	u.free = append(u.free, t)
+}
+
+func (u *sparseUniverse) Step(rule Rule) {
+	// Cells can only come alive in tiles with live cells and the tiles
+	// around them. Tiles that only hold dying cells still need aging.
+	clear(u.candidates)
+	for k, t := range u.tiles {
+		u.candidates[k] = true
+		if !t.hasLive() {
+			continue
+		}
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				u.candidates[tileKey{k.x + dx, k.y + dy}] = true
+			}
+		}
+	}
+
+	u.births, u.deaths = 0, 0
+	for k := range u.candidates {
+		if t := u.stepTile(k, rule); t != nil {
+			u.next[k] = t
+		}
+	}
+
+	for k, t := range u.tiles {
+		delete(u.tiles, k)
+		u.release(t)
+	}
+	u.tiles, u.next = u.next, u.tiles
+}
+
+// stepTile returns the tile at k in the next generation, or nil when it
+// would be empty.
+func (u *sparseUniverse) stepTile(k tileKey, rule Rule) *tile {
+	var around [3][3]*tile
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			around[dy+1][dx+1] = u.tiles[tileKey{k.x + dx, k.y + dy}]
+		}
+	}
+	cur := around[1][1]
+
+	// row returns row y of the tile column dx, where y may be one past
+	// either end of the tile and lands in the tile above or below.
+	row := func(dx, y int) uint64 {
+		ty := 1
+		if y < 0 {
+			ty, y = 0, tileSize-1
+		} else if y >= tileSize {
+			ty, y = 2, 0
+		}
+		if t := around[ty][dx]; t != nil {
+			return t.live[y]
+		}
+		return 0
+	}
+
+	out := u.alloc()
+	for y := 0; y < tileSize; y++ {
+		var n counter
+		for _, dy := range [...]int{-1, 0, 1} {
+			w, mid, e := row(0, y+dy), row(1, y+dy), row(2, y+dy)
+			n.add(mid<<1 | w>>63)
+			if dy != 0 {
+				n.add(mid)
+			}
+			n.add(mid>>1 | e<<63)
+		}
+
+		var live, dying uint64
+		if cur != nil {
+			live, dying = cur.live[y], cur.dying[y]
+		}
+		out.live[y] = rule.apply(&n, live, dying)
+		u.births += bits.OnesCount64(out.live[y] &^ live)
+		u.deaths += bits.OnesCount64(live &^ out.live[y])
+	}
+
+	if cur != nil && rule.states > 2 {
+		// The decay states move over to the new tile, nothing reads them
+		// from the old one.
+		out.decay, cur.decay = cur.decay, nil
+		for y := 0; y < tileSize; y++ {
+			if cur.live[y]&^out.live[y]|cur.dying[y] == 0 {
+				continue
+			}
+			if out.decay == nil {
+				out.decay = new([tileSize * tileSize]uint8)
+			}
+			out.dying[y] = ageDying(cur.live[y], out.live[y], cur.dying[y], out.decay[y*tileSize:], rule.states)
+		}
+	}
+
+	if out.empty() {
+		u.release(out)
+		return nil
+	}
+	return out
+}
+
+func (u *sparseUniverse) Population() int {
+	n := 0
+	for _, t := range u.tiles {
+		for _, w := range t.live {
+			n += bits.OnesCount64(w)
+		}
+	}
+	return n
+}
+
+func (u *sparseUniverse) Changes() (births, deaths int, ok bool) {
+	return u.births, u.deaths, true
+}
+
+// Hash adds up the hashes of the tiles, so that it does not depend on the
+// order the map is walked in.
+func (u *sparseUniverse) Hash() uint64 {
+	var sum uint64
+	for k, t := range u.tiles {
+		h := mixHash(mixHash(0, uint64(k.x)), uint64(k.y))
+		for y := 0; y < tileSize; y++ {
+			h = mixHash(mixHash(h, t.live[y]), t.dying[y])
+			for w := t.dying[y]; w != 0; w &= w - 1 {
+				h = mixHash(h, uint64(t.decay[y*tileSize+bits.TrailingZeros64(w)]))
+			}
+		}
+		sum += h
+	}
+	return sum
+}
+
+func (u *sparseUniverse) Bounds() (minX, minY, maxX, maxY int, ok bool) {
+	for k, t := range u.tiles {
+		for y := 0; y < tileSize; y++ {
+			w := t.live[y] | t.dying[y]
+			if w == 0 {
+				continue
+			}
+			x0 := k.x*tileSize + bits.TrailingZeros64(w)
+			x1 := k.x*tileSize + 63 - bits.LeadingZeros64(w)
+			cy := k.y*tileSize + y
+			if !ok {
+				minX, minY, maxX, maxY, ok = x0, cy, x1, cy, true
+			}
+			minX, minY = min(minX, x0), min(minY, cy)
+			maxX, maxY = max(maxX, x1), max(maxY, cy)
+		}
+	}
+	return minX, minY, maxX, maxY, ok
+}
+
+func (u *sparseUniverse) ForEachIn(x0, y0, x1, y1 int, fn func(x, y int, state uint8)) {
+	if x0 >= x1 || y0 >= y1 {
+		return
+	}
+	k0, _, _ := tileAt(x0, y0)
+	k1, _, _ := tileAt(x1-1, y1-1)
+
+	// Walk whichever is smaller: the tiles in the area or all the tiles.
+	if (k1.x-k0.x+1)*(k1.y-k0.y+1) > len(u.tiles) {
+		for k, t := range u.tiles {
+			if k.x >= k0.x && k.x <= k1.x && k.y >= k0.y && k.y <= k1.y {
+				forEachInTile(k, t, x0, y0, x1, y1, fn)
+			}
+		}
+		return
+	}
+	for ty := k0.y; ty <= k1.y; ty++ {
+		for tx := k0.x; tx <= k1.x; tx++ {
+			k := tileKey{tx, ty}
+			if t := u.tiles[k]; t != nil {
+				forEachInTile(k, t, x0, y0, x1, y1, fn)
+			}
+		}
+	}
+}
+
+func forEachInTile(k tileKey, t *tile, x0, y0, x1, y1 int, fn func(x, y int, state uint8)) {
+	for ty := 0; ty < tileSize; ty++ {
+		y := k.y*tileSize + ty
+		if y < y0 || y >= y1 {
+			continue
+		}
+		for w := t.live[ty] | t.dying[ty]; w != 0; w &= w - 1 {
+			tx := bits.TrailingZeros64(w)
+			x := k.x*tileSize + tx
+			if x < x0 || x >= x1 {
+				continue
+			}
+			state := uint8(1)
+			if t.live[ty]&(1<<uint(tx)) == 0 {
+				state = t.decay[ty*tileSize+tx]
+			}
+			fn(x, y, state)
+		}
+	}
+}
+
+func (u *sparseUniverse) Clone() Universe {
+	c := newSparseUniverse()
+	c.births, c.deaths = u.births, u.deaths
+	for k, t := range u.tiles {
+		ct := *t
+		if t.decay != nil {
+			decay := *t.decay
+			ct.decay = &decay
+		}
+		c.tiles[k] = &ct
+	}
+	return c
+}
+
+func (u *sparseUniverse) MemSize() int {
+	n := 0
+	for _, t := range u.tiles {
+		n += 2 * tileSize * 8
+		if t.decay != nil {
+			n += len(t.decay)
+		}
+	}
+	return n
+}
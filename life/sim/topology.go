@@ -0,0 +1,100 @@
+package sim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Topology decides what lies past the edges of the grid.
+type Topology int
+
+const (
+	// Bounded grids are surrounded by dead cells.
+	Bounded Topology = iota
+	// Torus joins the left edge to the right and the top edge to the bottom.
+	Torus
+	// KleinBottle joins the left and right edges like a torus, but the top
+	// edge meets the bottom one mirrored left to right.
+	KleinBottle
+	// CrossSurface joins both pairs of opposite edges mirrored, which makes
+	// the real projective plane.
+	CrossSurface
+	// Unbounded has no edges: the board is the whole plane.
+	Unbounded
+)
+
+var topologyNames = [...]string{
+	Bounded:      "Bounded",
+	Torus:        "Torus",
+	KleinBottle:  "Klein bottle",
+	CrossSurface: "Cross-surface",
+	Unbounded:    "Unbounded",
+}
+
+func (t Topology) String() string {
+	if t < 0 || int(t) >= len(topologyNames) {
+		return fmt.Sprintf("Topology(%d)", int(t))
+	}
+	return topologyNames[t]
+}
+
+// ParseTopology looks a topology up by name. Spaces and dashes are ignored,
+// so "klein", "Klein bottle" and "cross-surface" all work.
+func ParseTopology(s string) (Topology, error) {
+	normalize := func(s string) string {
+		s = strings.ToLower(s)
+		return strings.NewReplacer(" ", "", "-", "").Replace(s)
+	}
+	want := normalize(s)
+	for t, name := range topologyNames {
+		if n := normalize(name); n == want || want != "" && strings.HasPrefix(n, want) {
+			return Topology(t), nil
+		}
+	}
+	return Bounded, fmt.Errorf("unknown topology %q", s)
+}
+
+func (t Topology) Next() Topology {
+	return (t + 1) % Topology(len(topologyNames))
+}
+
+// Wrap maps the cell at x, y, which may lie past the edge of a grid with the
+// given size, to the cell of the grid it stands for. ok is false when the
+// cell is off a bounded grid. Unbounded boards have no edge to wrap around.
+func (t Topology) Wrap(x, y, rows, cols int) (wx, wy int, ok bool) {
+	if x >= 0 && x < cols && y >= 0 && y < rows {
+		return x, y, true
+	}
+
+	switch t {
+	case Torus:
+		return mod(x, cols), mod(y, rows), true
+	case KleinBottle:
+		x = mod(x, cols)
+		if y < 0 || y >= rows {
+			y = mod(y, rows)
+			x = cols - 1 - x
+		}
+		return x, y, true
+	case CrossSurface:
+		if x < 0 || x >= cols {
+			x = mod(x, cols)
+			y = rows - 1 - y
+		}
+		if y < 0 || y >= rows {
+			y = mod(y, rows)
+			x = cols - 1 - x
+		}
+		return x, y, true
+	}
+	return 0, 0, false
+}
+
+// mod is the remainder of a / b that is never negative.
+func mod(a, b int) int {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
@@ -0,0 +1,285 @@
+// Package sim runs Life-like and Generations cellular automata, with no
+// dependence on how or when the cells are drawn.
+//
+// Cells are addressed by column and row as x, y: x grows to the right and y
+// grows down. Sizes are given the other way round, as rows, cols, and a
+// finite board holds the cells with 0 <= x < cols and 0 <= y < rows.
+// Unbounded universes take any x, y, negative ones included.
+package sim
+
+import "math/bits"
+
+// Universe holds the cells of a board and steps them from one generation to
+// the next. Cell states are 0 for dead, 1 for alive and 2 and up for the
+// dying states of a Generations rule.
+type Universe interface {
+	Cell(x, y int) uint8
+	// SetCell changes a cell. Cells off a finite board are left alone.
+	SetCell(x, y int, state uint8)
+	Clear()
+
+	Step(rule Rule)
+
+	// Population is the number of live cells.
+	Population() int
+	// Changes returns how many cells were born and how many died in the
+	// last step. ok is false when the engine does not keep count.
+	Changes() (births, deaths int, ok bool)
+	// Hash returns a hash of the cells that are not dead. Equal boards hash
+	// the same.
+	Hash() uint64
+	// Bounds returns the smallest rectangle holding every cell that is not
+	// dead, with max inclusive. ok is false when there are no such cells.
+	Bounds() (minX, minY, maxX, maxY int, ok bool)
+	// ForEachIn calls fn for every cell that is not dead with x0 <= x < x1
+	// and y0 <= y < y1.
+	ForEachIn(x0, y0, x1, y1 int, fn func(x, y int, state uint8))
+
+	// Clone returns a copy of the universe that is not changed by stepping
+	// or editing the original.
+	Clone() Universe
+	// MemSize is roughly how many bytes a clone takes.
+	MemSize() int
+}
+
+// finiteUniverse is a rows x cols board stored in bit grids. What lies past
+// its edges is decided by its topology.
+type finiteUniverse struct {
+	rows     int
+	cols     int
+	topology Topology
+
+	live *bitGrid
+	// next is where a step writes the next generation. It is made by the
+	// first step, so that clones kept for history do not carry one.
+	next *bitGrid
+
+	// Cells in the refractory states of a Generations rule are set in
+	// dying and their state is kept in decay, indexed by y*cols+x. decay is
+	// only made once a cell starts dying.
+	dying *bitGrid
+	decay []uint8
+
+	births int
+	deaths int
+	// bandChanges holds the births and deaths of each band of a step.
+	bandChanges [][2]int
+}
+
+// NewFinite returns an empty board of rows x cols cells with the given
+// topology, which must not be Unbounded.
+func NewFinite(rows, cols int, topology Topology) Universe {
+	return &finiteUniverse{
+		rows:     rows,
+		cols:     cols,
+		topology: topology,
+
+		live:  newBitGrid(rows, cols),
+		dying: newBitGrid(rows, cols),
+	}
+}
+
+func (u *finiteUniverse) Cell(x, y int) uint8 {
+	if u.live.get(x, y) {
+		return 1
+	}
+	if u.dying.get(x, y) {
+		return u.decay[y*u.cols+x]
+	}
+	return 0
+}
+
+func (u *finiteUniverse) SetCell(x, y int, state uint8) {
+	if x < 0 || x >= u.cols || y < 0 || y >= u.rows {
+		return
+	}
+	u.live.set(x, y, state == 1)
+	u.dying.set(x, y, state >= 2)
+	if state >= 2 {
+		u.makeDecay()
+		u.decay[y*u.cols+x] = state
+	}
+}
+
+func (u *finiteUniverse) makeDecay() {
+	if u.decay == nil {
+		u.decay = make([]uint8, u.rows*u.cols)
+	}
+}
+
+func (u *finiteUniverse) Clear() {
+	u.live.clear()
+	u.dying.clear()
+}
+
+// Step runs bands of rows on stepPool. Each band only writes its own rows
+// of next, dying and decay, and only reads live around it, which no band
+// writes.
+func (u *finiteUniverse) Step(rule Rule) {
+	bands := stepPool.bands(u.rows)
+	if cap(u.bandChanges) < bands {
+		u.bandChanges = make([][2]int, bands)
+	}
+	u.bandChanges = u.bandChanges[:bands]
+	if u.next == nil {
+		u.next = newBitGrid(u.rows, u.cols)
+	}
+	if rule.states > 2 {
+		u.makeDecay()
+	}
+
+	stepPool.run(u.rows, bands, func(band, y0, y1 int) {
+		u.live.stepRows(u.next, rule, u.dying, u.topology, y0, y1)
+
+		var births, deaths int
+		for y := y0; y < y1; y++ {
+			cur, next, dying := u.live.row(y), u.next.row(y), u.dying.row(y)
+			for i := range next {
+				births += bits.OnesCount64(next[i] &^ cur[i])
+				deaths += bits.OnesCount64(cur[i] &^ next[i])
+			}
+			if rule.states > 2 {
+				for i := range dying {
+					dying[i] = ageDying(cur[i], next[i], dying[i], u.decay[y*u.cols+i*64:], rule.states)
+				}
+			}
+		}
+		u.bandChanges[band] = [2]int{births, deaths}
+	})
+
+	u.births, u.deaths = 0, 0
+	for _, c := range u.bandChanges {
+		u.births += c[0]
+		u.deaths += c[1]
+	}
+	u.live, u.next = u.next, u.live
+}
+
+func (u *finiteUniverse) Population() int {
+	return u.live.population()
+}
+
+func (u *finiteUniverse) Changes() (births, deaths int, ok bool) {
+	return u.births, u.deaths, true
+}
+
+func (u *finiteUniverse) Hash() uint64 {
+	var h uint64
+	for _, w := range u.live.words {
+		h = mixHash(h, w)
+	}
+	for i, w := range u.dying.words {
+		h = mixHash(h, w)
+		for ; w != 0; w &= w - 1 {
+			x := i%u.live.stride*64 + bits.TrailingZeros64(w)
+			h = mixHash(h, uint64(u.decay[i/u.live.stride*u.cols+x]))
+		}
+	}
+	return h
+}
+
+func (u *finiteUniverse) Bounds() (minX, minY, maxX, maxY int, ok bool) {
+	minX, minY, maxX, maxY = u.cols, u.rows, -1, -1
+	u.ForEachIn(0, 0, u.cols, u.rows, func(x, y int, _ uint8) {
+		minX, minY = min(minX, x), min(minY, y)
+		maxX, maxY = max(maxX, x), max(maxY, y)
+	})
+	return minX, minY, maxX, maxY, maxX >= 0
+}
+
+func (u *finiteUniverse) ForEachIn(x0, y0, x1, y1 int, fn func(x, y int, state uint8)) {
+	x0, y0 = max(x0, 0), max(y0, 0)
+	x1, y1 = min(x1, u.cols), min(y1, u.rows)
+	if x0 >= x1 {
+		return
+	}
+
+	for y := y0; y < y1; y++ {
+		live, dying := u.live.row(y), u.dying.row(y)
+		for i := x0 / 64; i <= (x1-1)/64; i++ {
+			for w := live[i] | dying[i]; w != 0; w &= w - 1 {
+				x := i*64 + bits.TrailingZeros64(w)
+				if x >= x0 && x < x1 {
+					fn(x, y, u.Cell(x, y))
+				}
+			}
+		}
+	}
+}
+
+func (u *finiteUniverse) Clone() Universe {
+	c := &finiteUniverse{
+		rows:     u.rows,
+		cols:     u.cols,
+		topology: u.topology,
+
+		live:  u.live.clone(),
+		dying: u.dying.clone(),
+
+		births: u.births,
+		deaths: u.deaths,
+	}
+	if c.dying.population() > 0 {
+		c.decay = append([]uint8(nil), u.decay...)
+	}
+	return c
+}
+
+func (u *finiteUniverse) MemSize() int {
+	n := 2*len(u.live.words)*8 + len(u.decay)
+	if u.next != nil {
+		n += len(u.next.words) * 8
+	}
+	return n
+}
+
+// ageDying returns the dying cells of a word of 64 cells after a generation
+// in which the live cells went from cur to next. Dying cells move on to their
+// next state, or back to dead once they have been through all of them, and
+// cells that did not survive start dying. decay holds the states of the
+// cells of the word and is updated in place.
+func ageDying(cur, next, dying uint64, decay []uint8, states int) uint64 {
+	for w := dying; w != 0; w &= w - 1 {
+		bit := bits.TrailingZeros64(w)
+		if s := int(decay[bit]) + 1; s < states {
+			decay[bit] = uint8(s)
+		} else {
+			decay[bit] = 0
+			dying &^= 1 << uint(bit)
+		}
+	}
+
+	for w := cur &^ next; w != 0; w &= w - 1 {
+		bit := bits.TrailingZeros64(w)
+		decay[bit] = 2
+		dying |= 1 << uint(bit)
+	}
+	return dying
+}
+
+// mixHash folds the word w into the hash h.
+func mixHash(h, w uint64) uint64 {
+	h ^= w
+	h *= 0x9e3779b97f4a7c15
+	return h ^ h>>29
+}
+
+// Step returns the generation after u under rule and leaves u as it is.
+// Stepping is a function of the cells and the rule alone, however long it
+// takes; how often to step is up to the caller.
+func Step(u Universe, rule Rule) Universe {
+	next := u.Clone()
+	next.Step(rule)
+	return next
+}
+
+// CopyCells copies every cell of src that is not dead into dst.
+func CopyCells(dst, src Universe) {
+	minX, minY, maxX, maxY, ok := src.Bounds()
+	if !ok {
+		return
+	}
+	src.ForEachIn(minX, minY, maxX+1, maxY+1, func(x, y int, state uint8) {
+		dst.SetCell(x, y, state)
+	})
+}
@@ -0,0 +1,140 @@
+package sim
+
+import "testing"
+
+var (
+	blinker = []string{
+		"...",
+		"OOO",
+		"...",
+	}
+	blinker2 = []string{
+		".O.",
+		".O.",
+		".O.",
+	}
+	block = []string{
+		"OO",
+		"OO",
+	}
+	glider = []string{
+		".O..",
+		"..O.",
+		"OOO.",
+		"....",
+	}
+	// glider4 is the glider four generations on, one cell down and right.
+	glider4 = []string{
+		"....",
+		"..O.",
+		"...O",
+		".OOO",
+	}
+	beehive = []string{
+		".OO.",
+		"O..O",
+		".OO.",
+	}
+)
+
+// TestStep runs small patterns through Step on a finite and an unbounded
+// universe. The patterns stay clear of the edges of the finite board, and
+// want is drawn with the same top left corner as cells.
+func TestStep(t *testing.T) {
+	tests := []struct {
+		name     string
+		cells    []string
+		rule     Rule
+		topology Topology
+		gens     int
+		want     []string
+	}{
+		{"blinker", blinker, Conway, Bounded, 1, blinker2},
+		{"blinker twice", blinker, Conway, Torus, 2, blinker},
+		{"block", block, Conway, Bounded, 5, block},
+		{"glider", glider, Conway, Torus, 4, glider4},
+		{"beehive", beehive, Conway, KleinBottle, 3, beehive},
+		{"block under Seeds", []string{
+			"....",
+			".OO.",
+			".OO.",
+			"....",
+		}, mustParseRule("", "B2/S"), Bounded, 1, []string{
+			".OO.",
+			"O..O",
+			"O..O",
+			".OO.",
+		}},
+	}
+	for _, tt := range tests {
+		for _, engine := range []struct {
+			name string
+			new  func() Universe
+		}{
+			{"finite", func() Universe { return NewFinite(16, 16, tt.topology) }},
+			{"sparse", NewSparse},
+		} {
+			t.Run(tt.name+"/"+engine.name, func(t *testing.T) {
+				start, want := engine.new(), engine.new()
+				place(start, tt.cells, 5, 5)
+				place(want, tt.want, 5, 5)
+
+				u := start
+				for i := 0; i < tt.gens; i++ {
+					u = Step(u, tt.rule)
+				}
+				if diff := sameCells(u, want); diff != "" {
+					t.Error(diff)
+				}
+				if tt.gens > 0 && start.Population() != countLive(tt.cells) {
+					t.Error("Step changed the universe it was given")
+				}
+			})
+		}
+	}
+}
+
+func countLive(pattern []string) int {
+	n := 0
+	for _, row := range pattern {
+		for _, ch := range row {
+			if ch == 'O' {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// TestFiniteClone checks that a clone, which has no next grid and no decay
+// of its own until it needs them, steps apart from the original, under a
+// Generations rule as well.
+func TestFiniteClone(t *testing.T) {
+	brain, err := ParseRule("Brian's Brain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := NewFinite(40, 70, Torus)
+	place(u, rPentomino, 30, 20)
+	c, want := u.Clone(), u.Clone()
+	for i := 0; i < 5; i++ {
+		u.Step(brain)
+	}
+	for i := 0; i < 5; i++ {
+		c.Step(Conway)
+		want.Step(Conway)
+	}
+	if diff := sameCells(c, want); diff != "" {
+		t.Fatal(diff)
+	}
+
+	c = u.Clone()
+	if c.MemSize() >= u.MemSize() {
+		t.Errorf("clone takes %d bytes, want less than the %d of a stepped board", c.MemSize(), u.MemSize())
+	}
+	u.Step(brain)
+	c.Step(brain)
+	if diff := sameCells(c, u); diff != "" {
+		t.Fatalf("dying cells: %s", diff)
+	}
+}
@@ -0,0 +1,79 @@
+package sim
+
+import (
+	"runtime"
+	"sync"
+)
+
+// minBandRows is the fewest rows worth handing to a worker. Smaller boards
+// are stepped on the calling goroutine.
+const minBandRows = 32
+
+// workerPool steps bands of rows on a set of goroutines, started as they are
+// first needed. Any number of boards may be stepped on it at once, from any
+// goroutines.
+type workerPool struct {
+	jobs chan func()
+
+	// mu guards workers, the most bands a step is split into, and started,
+	// how many goroutines take jobs.
+	mu      sync.Mutex
+	workers int
+	started int
+}
+
+// stepPool runs the steps of finite boards.
+var stepPool = &workerPool{jobs: make(chan func()), workers: runtime.GOMAXPROCS(0)}
+
+// Workers returns how many goroutines step finite boards.
+func Workers() int {
+	stepPool.mu.Lock()
+	defer stepPool.mu.Unlock()
+	return stepPool.workers
+}
+
+// SetWorkers sets how many goroutines step finite boards, at least one.
+// Steps already under way finish with the number they started with.
+func SetWorkers(n int) {
+	stepPool.mu.Lock()
+	defer stepPool.mu.Unlock()
+	stepPool.workers = max(n, 1)
+}
+
+// bands returns how many bands to split rows into.
+func (p *workerPool) bands(rows int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return max(1, min(p.workers, rows/minBandRows))
+}
+
+// run splits the rows 0 <= y < rows into the given number of bands and calls
+// fn for each band on its own worker, returning once every band is done.
+// band numbers the bands from 0.
+func (p *workerPool) run(rows, bands int, fn func(band, y0, y1 int)) {
+	if bands == 1 {
+		fn(0, 0, rows)
+		return
+	}
+
+	p.mu.Lock()
+	for ; p.started < bands; p.started++ {
+		go func() {
+			for job := range p.jobs {
+				job()
+			}
+		}()
+	}
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(bands)
+	for band := 0; band < bands; band++ {
+		band, y0, y1 := band, rows*band/bands, rows*(band+1)/bands
+		p.jobs <- func() {
+			fn(band, y0, y1)
+			wg.Done()
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,110 @@
+package sim
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// soup returns a rows x cols board with about half its cells alive, the same
+// for the same seed.
+func soup(rows, cols int, topology Topology, seed int64) Universe {
+	rng := rand.New(rand.NewSource(seed))
+	u := NewFinite(rows, cols, topology)
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			if rng.Intn(2) == 0 {
+				u.SetCell(x, y, 1)
+			}
+		}
+	}
+	return u
+}
+
+// withWorkers runs fn with the pool set to n workers.
+func withWorkers(n int, fn func()) {
+	was := Workers()
+	SetWorkers(n)
+	defer SetWorkers(was)
+	fn()
+}
+
+// stepped returns a soup run for gens generations under rule.
+func stepped(topology Topology, rule Rule, gens int) Universe {
+	u := soup(256, 300, topology, 7)
+	for i := 0; i < gens; i++ {
+		u.Step(rule)
+	}
+	return u
+}
+
+// TestBandsMatchOneBand steps boards split into bands by several numbers of
+// workers and checks them against the same boards stepped in one band.
+func TestBandsMatchOneBand(t *testing.T) {
+	starWars, err := ParseRule("Star Wars")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range []Rule{Conway, starWars} {
+		for _, topology := range []Topology{Bounded, Torus, KleinBottle, CrossSurface} {
+			var want Universe
+			withWorkers(1, func() { want = stepped(topology, rule, 20) })
+			for _, workers := range []int{2, 3, 4, 8} {
+				var got Universe
+				withWorkers(workers, func() { got = stepped(topology, rule, 20) })
+				if diff := sameCells(got, want); diff != "" {
+					t.Errorf("%s on %s, %d workers: %s", rule.Label(), topology, workers, diff)
+				}
+				gb, gd, _ := got.Changes()
+				wb, wd, _ := want.Changes()
+				if gb != wb || gd != wd {
+					t.Errorf("%s on %s, %d workers: %d births and %d deaths, want %d and %d", rule.Label(), topology, workers, gb, gd, wb, wd)
+				}
+			}
+		}
+	}
+}
+
+// TestBoardsStepAtOnce steps several boards on their own goroutines, sharing
+// the pool, while the number of workers changes.
+func TestBoardsStepAtOnce(t *testing.T) {
+	var want Universe
+	withWorkers(1, func() { want = stepped(Torus, Conway, 30) })
+
+	withWorkers(4, func() {
+		got := make([]Universe, 4)
+		var wg sync.WaitGroup
+		for i := range got {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				got[i] = stepped(Torus, Conway, 30)
+			}(i)
+		}
+		for n := 1; n <= 8; n++ {
+			SetWorkers(n)
+		}
+		wg.Wait()
+
+		for i, u := range got {
+			if diff := sameCells(u, want); diff != "" {
+				t.Errorf("board %d: %s", i, diff)
+			}
+		}
+	})
+}
+
+func BenchmarkStep(b *testing.B) {
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			withWorkers(workers, func() {
+				u := soup(2000, 2000, Torus, 1)
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					u.Step(Conway)
+				}
+			})
+		})
+	}
+}
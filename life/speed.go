@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// speeds are the simulation speeds + and - move between, in steps per
+// second. 0 runs as many steps as fit in a frame.
+var speeds = []float64{1, 2, 5, 10, 20, 30, 60, 120, 240, 480, 960, 1920, 0}
+
+// defaultSpeed is the index in speeds of 5 steps a second.
+const defaultSpeed = 2
+
+// frameBudget is the longest update spends stepping in one frame, so that the
+// window keeps responding at high speeds.
+const frameBudget = 12 * time.Millisecond
+
+// rateInterval is how often the measured generations per second are updated.
+const rateInterval = 500 * time.Millisecond
+
+// advance runs as many steps as the speed asks for since the last frame.
+func (g *Grid) advance(now time.Time) {
+	elapsed := now.Sub(g.lastUpdate)
+	g.lastUpdate = now
+	if !g.run {
+		g.owed = 0
+		return
+	}
+
+	// A step may stop the run, when the board stabilises with autoPause on,
+	// and then no more steps are run.
+	deadline := now.Add(frameBudget)
+	perSecond := speeds[g.speed]
+	if perSecond == 0 {
+		for ok := true; ok && g.run; ok = time.Now().Before(deadline) {
+			g.step()
+		}
+		return
+	}
+
+	g.owed += elapsed.Seconds() * perSecond
+	for g.run && g.owed >= 1 && time.Now().Before(deadline) {
+		g.step()
+		g.owed--
+	}
+	// Steps that did not fit in the frame are dropped rather than piling
+	// up, the speed is a target and not a promise.
+	g.owed = min(g.owed, 1)
+}
+
+// measureRate updates gensPerSecond from the generations run since it was
+// last measured.
+func (g *Grid) measureRate(now time.Time) {
+	elapsed := now.Sub(g.rateFrom)
+	if elapsed < rateInterval {
+		return
+	}
+	g.gensPerSecond = float64(g.generation-g.rateGeneration) / elapsed.Seconds()
+	if g.gensPerSecond < 0 {
+		// Stepped back or cleared.
+		g.gensPerSecond = 0
+	}
+	g.rateFrom, g.rateGeneration = now, g.generation
+}
+
+func (g *Grid) speedLabel() string {
+	if speeds[g.speed] == 0 {
+		return "Max"
+	}
+	return fmt.Sprintf("%g/s", speeds[g.speed])
+}
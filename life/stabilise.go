@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+
+	"epractice/life/sim"
+)
+
+// cycleWindow is how many generations back the cycle detector looks, and so
+// the longest period it finds.
+const cycleWindow = 1 << 12
+
+// cycleDetector notices when the board comes back to a state it was in
+// before. From then on it goes round the same states forever, so the first
+// repeat gives both where the cycle starts and its period.
+type cycleDetector struct {
+	// seen maps the hashes of the last states to their generations. recent
+	// holds the same hashes in a ring, next being the oldest once it is
+	// full, to know what to forget.
+	seen   map[uint64]int
+	recent []uint64
+	next   int
+
+	// stride is how many generations a step moves on, more than one with
+	// HashLife. Only every stride-th generation is seen then, so a repeat
+	// bounds the period and the start of the cycle rather than giving them.
+	stride int
+
+	found  bool
+	empty  bool
+	start  int
+	period int
+}
+
+// reset forgets every state, for when the board is changed by hand.
+func (d *cycleDetector) reset() {
+	clear(d.seen)
+	d.recent = d.recent[:0]
+	d.next = 0
+	d.found = false
+}
+
+// check records the state of the board at a generation and reports whether
+// it closes a cycle.
+func (d *cycleDetector) check(hash uint64, generation, population int) bool {
+	if d.found {
+		return false
+	}
+	if d.seen == nil {
+		d.seen = make(map[uint64]int)
+	}
+
+	if start, ok := d.seen[hash]; ok {
+		d.found = true
+		d.empty = population == 0
+		d.start, d.period = start, generation-start
+		return true
+	}
+
+	if len(d.recent) < cycleWindow {
+		d.recent = append(d.recent, hash)
+	} else {
+		delete(d.seen, d.recent[d.next])
+		d.recent[d.next] = hash
+		d.next = (d.next + 1) % cycleWindow
+	}
+	d.seen[hash] = generation
+	return false
+}
+
+// String describes the cycle found, or is empty when there is none yet.
+func (d *cycleDetector) String() string {
+	switch {
+	case !d.found:
+		return ""
+	case d.stride > 1 && d.empty:
+		return fmt.Sprintf("Stabilised: empty by generation %d", d.start)
+	case d.stride > 1:
+		// The state seen twice is in the cycle, so the period divides
+		// the generations between the two.
+		return fmt.Sprintf("Stabilised: period divides %d, by generation %d", d.period, d.start)
+	case d.empty:
+		return fmt.Sprintf("Stabilised: empty after %d generations", d.start)
+	case d.period == 1:
+		return fmt.Sprintf("Stabilised: static after %d generations", d.start)
+	}
+	return fmt.Sprintf("Stabilised: period %d after %d generations", d.period, d.start)
+}
+
+// checkCycle looks for a cycle at the current generation and stops the run
+// on finding one when autoPause is on. The detector starts over when the
+// HashLife step changes, so that it never compares steps of different sizes.
+func (g *Grid) checkCycle() {
+	stride := 1
+	if _, ok := g.cells.(*sim.HashLife); ok {
+		stride = 1 << g.jump
+	}
+	if stride != g.cycle.stride {
+		g.cycle.reset()
+		g.cycle.stride = stride
+	}
+	if !g.cycle.check(g.cells.Hash(), g.generation, g.cells.Population()) {
+		return
+	}
+	if g.autoPause {
+		g.run = false
+	}
+}
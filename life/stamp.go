@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/colornames"
+)
+
+// stampFiles holds the patterns of the stamp palette.
+//
+//go:embed stamps/*.rle
+var stampFiles embed.FS
+
+// stampCatalogue lists the stamps in the order the palette shows them.
+var stampCatalogue = loadStamps(
+	"glider", "lwss", "mwss", "hwss",
+	"blinker", "toad", "beacon", "pulsar", "pentadecathlon",
+	"block", "beehive", "loaf", "boat",
+	"gosper-glider-gun",
+	"r-pentomino", "acorn", "diehard", "infinite-growth",
+)
+
+func loadStamps(names ...string) []*Pattern {
+	stamps := make([]*Pattern, len(names))
+	for i, name := range names {
+		path := "stamps/" + name + ".rle"
+		data, err := stampFiles.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+		p, err := readRLE(bytes.NewReader(data))
+		if err != nil {
+			panic(fmt.Sprintf("%s: %v", path, err))
+		}
+		stamps[i] = p
+	}
+	return stamps
+}
+
+// pickStamp makes a left click stamp p instead of toggling a cell.
+func (g *Grid) pickStamp(p *Pattern) {
+	g.stamp = p
+	g.notice = "Stamp: " + p.name + ", R to rotate, F to flip, Esc to go back to toggling cells"
+}
+
+// stampOrigin returns where the top left corner of the stamp goes when it is
+// centred on the screen pixel mx, my.
+func (g *Grid) stampOrigin(mx, my int) (x, y int) {
+	x, y = g.camera.toWorld(mx-g.startX, my-g.startY)
+	return x - g.stamp.width/2, y - g.stamp.height/2
+}
+
+// stampAt stamps the stamp centred on the screen pixel mx, my. The cells of
+// the stamp that are dead leave the grid as it was.
+func (g *Grid) stampAt(mx, my int) {
+	if !g.inView(mx, my) {
+		return
+	}
+
+	x0, y0 := g.stampOrigin(mx, my)
+	g.edit()
+	for y := 0; y < g.stamp.height; y++ {
+		for x := 0; x < g.stamp.width; x++ {
+			if s := g.stamp.at(x, y); s != 0 {
+				g.cells.SetCell(x0+x, y0+y, s)
+			}
+		}
+	}
+}
+
+// ghostColor is the colour of the preview of the stamp under the cursor.
+var ghostColor = color.RGBA{40, 100, 128, 128}
+
+// drawStamp draws a preview of where the stamp would go when the cursor is
+// over the view.
+func (g *Grid) drawStamp(screen *ebiten.Image, mx, my int) {
+	if g.stamp == nil || !g.inView(mx, my) {
+		return
+	}
+
+	view := screen.SubImage(image.Rect(g.startX, g.startY, g.startX+g.viewWidth, g.startY+g.viewHeight)).(*ebiten.Image)
+	zoom := float32(g.camera.zoom)
+	x0, y0 := g.stampOrigin(mx, my)
+	for y := 0; y < g.stamp.height; y++ {
+		for x := 0; x < g.stamp.width; x++ {
+			if g.stamp.at(x, y) == 0 {
+				continue
+			}
+			px, py := g.camera.toView(x0+x, y0+y)
+			vector.DrawFilledRect(view, float32(g.startX)+float32(px), float32(g.startY)+float32(py), zoom, zoom, ghostColor, false)
+		}
+	}
+}
+
+const paletteLineHeight = 16
+
+// paletteEntryAt returns the index in stampCatalogue of the palette entry
+// under the screen pixel mx, my, or -1 when there is none.
+func paletteEntryAt(mx, my int) int {
+	if mx < grid.startX || mx >= grid.startX+grid.viewWidth {
+		return -1
+	}
+	// Entries start one line below the heading.
+	top := grid.startY + 18 + paletteLineHeight
+	if my < top {
+		return -1
+	}
+	if i := (my - top) / paletteLineHeight; i < len(stampCatalogue) {
+		return i
+	}
+	return -1
+}
+
+func drawPalette(screen *ebiten.Image, mx, my int) {
+	x, y := grid.startX+20, grid.startY+30
+
+	height := float32((len(stampCatalogue)+1)*paletteLineHeight + 40)
+	vector.DrawFilledRect(screen, float32(grid.startX), float32(grid.startY), float32(grid.viewWidth), height, color.RGBA{0, 0, 0, 220}, false)
+	text.Draw(screen, "Click a stamp to pick it, P to close", TechnoRaceSmall, x, y, color.White)
+
+	hover := paletteEntryAt(mx, my)
+	for i, p := range stampCatalogue {
+		line := fmt.Sprintf("%s  (%dx%d)", p.name, p.width, p.height)
+		clr := color.Color(color.White)
+		if i == hover {
+			clr = colornames.Lightskyblue
+		}
+		text.Draw(screen, line, TechnoRaceSmall, x, y+(i+1)*paletteLineHeight, clr)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// statSample is the state of the board at one generation.
+type statSample struct {
+	generation int
+	population int
+	// births and deaths are for the step that led to the generation, -1
+	// when the engine does not count them.
+	births int
+	deaths int
+}
+
+// maxStatSamples is how many samples are kept before the oldest half is
+// dropped.
+const maxStatSamples = 1 << 20
+
+// stats is the population of the board over time, one sample per
+// generation.
+type stats struct {
+	samples []statSample
+
+	// bounds are the bounds of the cells when Grid.version was
+	// boundsVersion, kept since finding them walks every live cell.
+	bounds        image.Rectangle
+	boundsKnown   bool
+	boundsVersion int
+}
+
+// record adds a sample. Samples of the same generation or later ones are
+// replaced, so going back in time or editing the board rewrites the series
+// from there.
+func (s *stats) record(sample statSample) {
+	for n := len(s.samples); n > 0 && s.samples[n-1].generation >= sample.generation; n-- {
+		s.samples = s.samples[:n-1]
+	}
+	if len(s.samples) == maxStatSamples {
+		s.samples = append(s.samples[:0], s.samples[maxStatSamples/2:]...)
+	}
+	s.samples = append(s.samples, sample)
+}
+
+func (s *stats) last() statSample {
+	if len(s.samples) == 0 {
+		return statSample{births: -1, deaths: -1}
+	}
+	return s.samples[len(s.samples)-1]
+}
+
+func writeStatsHeader(w io.Writer) error {
+	_, err := fmt.Fprintln(w, "generation,population,births,deaths")
+	return err
+}
+
+func writeStatsRow(w io.Writer, s statSample) error {
+	if s.births < 0 {
+		_, err := fmt.Fprintf(w, "%d,%d,,\n", s.generation, s.population)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d,%d,%d,%d\n", s.generation, s.population, s.births, s.deaths)
+	return err
+}
+
+// writeCSV writes the series as CSV with a header line.
+func (s *stats) writeCSV(w io.Writer) error {
+	if err := writeStatsHeader(w); err != nil {
+		return err
+	}
+	for _, sample := range s.samples {
+		if err := writeStatsRow(w, sample); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sample returns the current state of the board.
+func (g *Grid) sample() statSample {
+	s := statSample{generation: g.generation, population: g.cells.Population()}
+	births, deaths, ok := g.cells.Changes()
+	if !ok {
+		births, deaths = -1, -1
+	}
+	s.births, s.deaths = births, deaths
+	return s
+}
+
+// exportStats writes the population series to the file at path.
+// cellBounds returns the cells that are not dead as a rectangle, empty when
+// there are none, working them out again only when the board has changed.
+func (g *Grid) cellBounds() image.Rectangle {
+	s := &g.stats
+	if !s.boundsKnown || s.boundsVersion != g.version {
+		s.bounds = image.Rectangle{}
+		if minX, minY, maxX, maxY, ok := g.cells.Bounds(); ok {
+			s.bounds = image.Rect(minX, minY, maxX+1, maxY+1)
+		}
+		s.boundsKnown, s.boundsVersion = true, g.version
+	}
+	return s.bounds
+}
+
+func (g *Grid) exportStats(path string) {
+	err := func() error {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		w := bufio.NewWriter(f)
+		if err := g.stats.writeCSV(w); err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		return f.Close()
+	}()
+	if err != nil {
+		g.notice = "Export failed: " + err.Error()
+		return
+	}
+	g.notice = fmt.Sprintf("Exported %d generations to %s", len(g.stats.samples), path)
+}
+
+const (
+	statsPanelWidth = 240
+	chartHeight     = 150
+)
+
+var chartLineColor = color.RGBA{120, 220, 120, 255}
+
+// drawStats draws the statistics panel to the right of the view, with a
+// chart of the population over the last generations, one pixel each.
+func drawStats(screen *ebiten.Image) {
+	const lineHeight = 16
+	x, y := grid.startX+grid.viewWidth+margin, grid.startY+12
+
+	s := grid.stats.last()
+	lines := []string{
+		"Statistics",
+		fmt.Sprintf("Generation:  %d", s.generation),
+		fmt.Sprintf("Population:  %d", s.population),
+	}
+	if s.births < 0 {
+		lines = append(lines, "Births, deaths:  not counted by HashLife")
+	} else {
+		lines = append(lines, fmt.Sprintf("Births:  %d   Deaths:  %d", s.births, s.deaths))
+	}
+	if b := grid.cellBounds(); !b.Empty() {
+		lines = append(lines, fmt.Sprintf("Bounds:  %dx%d at %d, %d", b.Dx(), b.Dy(), b.Min.X, b.Min.Y))
+	} else {
+		lines = append(lines, "Bounds:  empty")
+	}
+	for i, line := range lines {
+		text.Draw(screen, line, TechnoRaceSmall, x, y+i*lineHeight, color.White)
+	}
+
+	top := y + len(lines)*lineHeight
+	vector.DrawFilledRect(screen, float32(x), float32(top), statsPanelWidth, chartHeight, outsideBoardColor, false)
+
+	samples := grid.stats.samples
+	if n := len(samples); n > statsPanelWidth {
+		samples = samples[n-statsPanelWidth:]
+	}
+	highest := 1
+	for _, s := range samples {
+		highest = max(highest, s.population)
+	}
+	bottom := float32(top + chartHeight)
+	scale := float32(chartHeight-4) / float32(highest)
+	for i := 1; i < len(samples); i++ {
+		x0, x1 := float32(x+i-1), float32(x+i)
+		y0 := bottom - float32(samples[i-1].population)*scale
+		y1 := bottom - float32(samples[i].population)*scale
+		vector.StrokeLine(screen, x0, y0, x1, y1, 1, chartLineColor, false)
+	}
+
+	text.Draw(screen, fmt.Sprintf("max %d", highest), TechnoRaceSmall, x+4, top+12, color.White)
+	if len(samples) > 0 {
+		msg := fmt.Sprintf("gens %d to %d", samples[0].generation, samples[len(samples)-1].generation)
+		text.Draw(screen, msg, TechnoRaceSmall, x, top+chartHeight+lineHeight, color.White)
+	}
+	text.Draw(screen, "F2 to export as CSV", TechnoRaceSmall, x, top+chartHeight+2*lineHeight, color.White)
+}